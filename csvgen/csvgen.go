@@ -0,0 +1,3480 @@
+// Package csvgen generates CSV and JSON files of fake data for testing.
+package csvgen
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/brianvoe/gofakeit/v7"
+)
+
+type FileHandler interface {
+	MkDirAll(path string, perm os.FileMode) error
+	Create(name string) (io.WriteCloser, error)
+	// OpenAppend opens name for appending, creating it if it doesn't exist, and returns
+	// the size of its existing content.
+	OpenAppend(name string) (io.WriteCloser, int64, error)
+	// Exists reports whether a file or directory called name exists.
+	Exists(name string) (bool, error)
+	// Open opens name for reading, which is used to verify the generated file.
+	Open(name string) (io.ReadCloser, error)
+	// Remove deletes the file called name, which is used to remove a partial file.
+	Remove(name string) error
+	// Rename moves oldName to newName, replacing newName if it exists.
+	Rename(oldName string, newName string) error
+}
+
+type OSFileHandler struct{}
+
+func (c OSFileHandler) MkDirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (c OSFileHandler) Create(name string) (io.WriteCloser, error) {
+	return os.Create(name)
+}
+
+func (c OSFileHandler) OpenAppend(name string) (io.WriteCloser, int64, error) {
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+
+	return file, info.Size(), nil
+}
+
+func (c OSFileHandler) Exists(name string) (bool, error) {
+	_, err := os.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (c OSFileHandler) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (c OSFileHandler) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (c OSFileHandler) Rename(oldName string, newName string) error {
+	return os.Rename(oldName, newName)
+}
+
+// stdoutFilename is the filename used to request the generated data be written to
+// stdout instead of a file.
+const stdoutFilename = "-"
+
+// nopCloser wraps a writer that shouldn't be closed once generation finishes, such as
+// os.Stdout.
+type nopCloser struct {
+	io.Writer
+}
+
+func (n nopCloser) Close() error {
+	return nil
+}
+
+// RecordWriter writes CSV records, it's implemented by *csv.Writer and by
+// alwaysQuoteWriter.
+type RecordWriter interface {
+	Write(record []string) error
+	Flush()
+	Error() error
+}
+
+type FileWriter interface {
+	Write(record []string, writer RecordWriter) error
+}
+
+type CSVFileWriter struct{}
+
+func (c CSVFileWriter) Write(record []string, writer RecordWriter) error {
+	return writer.Write(record)
+}
+
+// alwaysQuoteWriter writes CSV records with every field quoted, for parsers that expect
+// it. csv.Writer only quotes the fields that need it.
+type alwaysQuoteWriter struct {
+	w       io.Writer
+	comma   rune
+	useCRLF bool
+	err     error
+}
+
+func newAlwaysQuoteWriter(w io.Writer, comma rune, useCRLF bool) *alwaysQuoteWriter {
+	return &alwaysQuoteWriter{w: w, comma: comma, useCRLF: useCRLF}
+}
+
+func (a *alwaysQuoteWriter) Write(record []string) error {
+	if a.err != nil {
+		return a.err
+	}
+
+	var buf bytes.Buffer
+	for i, field := range record {
+		if i > 0 {
+			buf.WriteRune(a.comma)
+		}
+
+		buf.WriteByte('"')
+		buf.WriteString(strings.ReplaceAll(field, `"`, `""`))
+		buf.WriteByte('"')
+	}
+	if a.useCRLF {
+		buf.WriteString("\r\n")
+	} else {
+		buf.WriteByte('\n')
+	}
+
+	_, a.err = a.w.Write(buf.Bytes())
+	return a.err
+}
+
+// Flush is a no-op, records are written straight through to the underlying writer.
+func (a *alwaysQuoteWriter) Flush() {}
+
+func (a *alwaysQuoteWriter) Error() error {
+	return a.err
+}
+
+var validFields = map[string]bool{
+	"name":       true,
+	"age":        true,
+	"email":      true,
+	"firstName":  true,
+	"lastName":   true,
+	"middleName": true,
+	"city":       true,
+	"jobTitle":   true,
+	"address":    true,
+	"zip":        true,
+	"state":      true,
+	"uuid":       true,
+	"company":    true,
+	"birthdate":  true,
+	"active":     true,
+	"bool":       true,
+	"latitude":   true,
+	"longitude":  true,
+	"price":      true,
+	"gender":     true,
+	"username":   true,
+	"country":    true,
+	"color":      true,
+	"hexcolor":   true,
+	"id":         true,
+	"sentence":   true,
+	"paragraph":  true,
+	"url":        true,
+	"ssn":        true,
+	"mac":        true,
+	"animal":     true,
+	"animaltype": true,
+	"petname":    true,
+	"food":       true,
+	"fruit":      true,
+	"vegetable":  true,
+	"dessert":    true,
+	"emoji":      true,
+	"hobby":      true,
+	"httpstatus": true,
+	"progress":   true,
+	"jsonblob":   true,
+	"salary":     true,
+	// The job fields share the row's job, so they describe a single position at the
+	// company in the company field. The department is one the job's title fits in.
+	"job":           true,
+	"jobDescriptor": true,
+	"jobLevel":      true,
+	"department":    true,
+	// The currency fields share the row's currency, so the code matches the name.
+	"currency":     true,
+	"currencyname": true,
+	// The language fields are drawn independently, so the abbreviation isn't the code of
+	// the language in the language field.
+	"language":     true,
+	"languageabbr": true,
+	// The time zone fields use the zone of the row's state when an address field is
+	// selected, otherwise they're drawn independently.
+	"timezone":     true,
+	"timezoneabbr": true,
+	// The password fields share the row's password when both are selected, so the hash
+	// is the hash of the password in the password field.
+	"password":     true,
+	"passwordhash": true,
+	// The credit card fields share the row's card when creditcard is selected with
+	// ccexpiry or cvv, so the expiry and CVV belong to the card number.
+	"creditcard": true,
+	"ccexpiry":   true,
+	"cvv":        true,
+}
+
+// rowContext holds the state available to a fieldGenerator while generating a row.
+// Random values are drawn from faker rather than the package level gofakeit functions
+// so that concurrent generation stays reproducible for a given seed.
+type rowContext struct {
+	faker  *gofakeit.Faker
+	fields BaseFields
+	opts   Options
+	// hasAddress reports whether any of the addressFields are selected.
+	hasAddress bool
+	// row is the index of the row being generated, starting at 0.
+	row int64
+	// ssnOffset is where the run's sequence of ssn values starts, the same for every
+	// worker.
+	ssnOffset int64
+	// currency is the row's currency, drawn once per row when a currency field is
+	// selected.
+	currency *gofakeit.CurrencyInfo
+}
+
+// fieldGenerator generates the value of a field for a single row.
+type fieldGenerator func(rc rowContext) string
+
+var generators = map[string]fieldGenerator{
+	"name":       func(rc rowContext) string { return rc.fields.Name },
+	"age":        generateAge,
+	"email":      func(rc rowContext) string { return rc.fields.Email },
+	"firstName":  func(rc rowContext) string { return rc.fields.FirstName },
+	"lastName":   func(rc rowContext) string { return rc.fields.LastName },
+	"middleName": func(rc rowContext) string { return rc.faker.MiddleName() },
+	"city":       func(rc rowContext) string { return rc.fields.Address.City },
+	"jobTitle":   func(rc rowContext) string { return rc.fields.Job.Title },
+	"zip":        func(rc rowContext) string { return rc.fields.Address.Zip },
+	"state":      func(rc rowContext) string { return rc.fields.Address.State },
+	"uuid":       func(rc rowContext) string { return rc.faker.UUID() },
+	"company":    generateCompany,
+	"birthdate":  generateBirthdate,
+	"active":     generateBool,
+	"bool":       generateBool,
+	"latitude":   func(rc rowContext) string { return formatCoordinate(rc.fields.Address.Latitude, rc.opts) },
+	"longitude":  func(rc rowContext) string { return formatCoordinate(rc.fields.Address.Longitude, rc.opts) },
+	"price": func(rc rowContext) string {
+		return formatPrice(rc.faker.Price(defaultMinPrice, defaultMaxPrice), rc.opts)
+	},
+	"salary": func(rc rowContext) string {
+		return formatInt(skewedNumber(rc.faker, defaultMinSalary, defaultMaxSalary), rc.opts)
+	},
+	"gender":        func(rc rowContext) string { return rc.fields.Gender },
+	"username":      func(rc rowContext) string { return rc.fields.Username },
+	"country":       generateCountry,
+	"color":         func(rc rowContext) string { return rc.faker.Color() },
+	"hexcolor":      func(rc rowContext) string { return rc.faker.HexColor() },
+	"id":            func(rc rowContext) string { return formatID(rc.row+1, rc.opts) },
+	"sentence":      func(rc rowContext) string { return rc.faker.Sentence(defaultSentenceWords) },
+	"paragraph":     func(rc rowContext) string { return generateParagraph(rc, defaultParagraphSentences) },
+	"url":           generateURL,
+	"ssn":           generateSSN,
+	"mac":           func(rc rowContext) string { return rc.faker.MacAddress() },
+	"animal":        func(rc rowContext) string { return rc.faker.Animal() },
+	"animaltype":    func(rc rowContext) string { return rc.faker.AnimalType() },
+	"petname":       func(rc rowContext) string { return rc.faker.PetName() },
+	"food":          generateFood,
+	"fruit":         func(rc rowContext) string { return rc.faker.Fruit() },
+	"vegetable":     func(rc rowContext) string { return rc.faker.Vegetable() },
+	"dessert":       func(rc rowContext) string { return rc.faker.Dessert() },
+	"emoji":         func(rc rowContext) string { return rc.faker.Emoji() },
+	"hobby":         func(rc rowContext) string { return rc.faker.Hobby() },
+	"httpstatus":    generateHTTPStatus,
+	"progress":      generateProgress,
+	"jsonblob":      generateJSONBlob,
+	"language":      func(rc rowContext) string { return rc.faker.Language() },
+	"languageabbr":  func(rc rowContext) string { return rc.faker.LanguageAbbreviation() },
+	"timezone":      generateTimeZone,
+	"timezoneabbr":  generateTimeZoneAbbr,
+	"password":      generatePassword,
+	"passwordhash":  generatePasswordHash,
+	"creditcard":    generateCreditCard,
+	"ccexpiry":      generateCreditCardExpiry,
+	"cvv":           generateCreditCardCvv,
+	"job":           generateJob,
+	"jobDescriptor": func(rc rowContext) string { return rc.fields.Job.Descriptor },
+	"jobLevel":      func(rc rowContext) string { return rc.fields.Job.Level },
+	"department":    generateDepartment,
+	"currency":      func(rc rowContext) string { return rc.currency.Short },
+	"currencyname":  func(rc rowContext) string { return rc.currency.Long },
+	// The full address contains commas, the csv.Writer quotes any field containing the
+	// delimiter so the address is still read back as a single column.
+	"address": func(rc rowContext) string { return rc.fields.Address.Address },
+}
+
+const (
+	// ssnSerials is the number of distinct ssn values, the 8 digits following the
+	// leading 9.
+	ssnSerials = 100_000_000
+	// ssnStride spreads consecutive rows across the range of ssn values. It shares no
+	// factors with ssnSerials, so no value repeats until every value has been used.
+	ssnStride = 38_461_537
+)
+
+// newSSNOffset returns the start of the run's ssn sequence, drawn from a faker of its own
+// so selecting ssn doesn't change the values of the other fields.
+func newSSNOffset(seed int) int64 {
+	ssn, _ := strconv.ParseInt(gofakeit.New(uint64(seed)).SSN(), 10, 64)
+	return ssn % ssnSerials
+}
+
+// generateSSN returns a fake social security number in the 900-999 area range, which is
+// never issued as an SSN, so the values can't belong to a real person. The number is
+// derived from the row index, every row of a run gets a distinct number for the first
+// 100 million rows.
+func generateSSN(rc rowContext) string {
+	serial := (rc.ssnOffset + rc.row%ssnSerials*ssnStride) % ssnSerials
+	digits := fmt.Sprintf("9%08d", serial)
+
+	return digits[:3] + "-" + digits[3:5] + "-" + digits[5:]
+}
+
+// generateJob returns the row's full job title, made up of the descriptor, level and
+// title, e.g. "Senior Markets Engineer".
+func generateJob(rc rowContext) string {
+	return rc.fields.Job.Descriptor + " " + rc.fields.Job.Level + " " + rc.fields.Job.Title
+}
+
+// departments are the values of the department field.
+var departments = []string{
+	"Customer Support", "Design", "Engineering", "Finance", "HR", "IT", "Legal", "Marketing",
+	"Operations", "Product", "Research", "Sales",
+}
+
+// titleDepartments maps the job titles that only fit in some departments to those
+// departments. The other titles, such as Manager or Analyst, fit in any department.
+var titleDepartments = map[string][]string{
+	"Architect":      {"Engineering", "IT"},
+	"Developer":      {"Engineering", "IT"},
+	"Engineer":       {"Engineering", "IT"},
+	"Technician":     {"Engineering", "IT", "Operations"},
+	"Designer":       {"Design", "Marketing", "Product"},
+	"Agent":          {"Customer Support", "Sales"},
+	"Representative": {"Customer Support", "Sales"},
+	"Producer":       {"Marketing"},
+	"Strategist":     {"Marketing", "Product", "Sales"},
+	"Planner":        {"Finance", "Operations"},
+}
+
+// generateDepartment returns a department the row's job title fits in when a job field is
+// selected, or any of the departments otherwise.
+func generateDepartment(rc rowContext) string {
+	if rc.fields.Job != nil {
+		if options, ok := titleDepartments[rc.fields.Job.Title]; ok {
+			return rc.faker.RandomString(options)
+		}
+	}
+
+	return rc.faker.RandomString(departments)
+}
+
+// passwordLength is the number of characters in a generated password.
+const passwordLength = 12
+
+// newPassword returns a random password of lower and upper case letters, digits and
+// special characters.
+func newPassword(faker *gofakeit.Faker) string {
+	return faker.Password(true, true, true, true, false, passwordLength)
+}
+
+// generatePassword returns the row's password when the passwordhash field is also
+// selected, so the hash matches it, or a random password otherwise.
+func generatePassword(rc rowContext) string {
+	if rc.fields.Password != "" {
+		return rc.fields.Password
+	}
+
+	return newPassword(rc.faker)
+}
+
+// generatePasswordHash returns the hex encoded SHA-256 hash of the row's password when
+// the password field is also selected, or of a random password otherwise. SHA-256 isn't
+// suitable for storing real passwords, but it's quick to check in a test.
+func generatePasswordHash(rc rowContext) string {
+	password := rc.fields.Password
+	if password == "" {
+		password = newPassword(rc.faker)
+	}
+
+	hash := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(hash[:])
+}
+
+// generateCreditCard returns the number of the row's credit card when the ccexpiry or cvv
+// field is also selected, or a random card number otherwise.
+func generateCreditCard(rc rowContext) string {
+	if rc.fields.CreditCard != nil {
+		return rc.fields.CreditCard.Number
+	}
+
+	return rc.faker.CreditCardNumber(nil)
+}
+
+// generateCreditCardExpiry returns the expiry of the row's credit card as MM/YY when the
+// creditcard field is also selected, or a random expiry otherwise.
+func generateCreditCardExpiry(rc rowContext) string {
+	if rc.fields.CreditCard != nil {
+		return rc.fields.CreditCard.Exp
+	}
+
+	return rc.faker.CreditCardExp()
+}
+
+// generateCreditCardCvv returns the CVV of the row's credit card when the creditcard field
+// is also selected, or a random CVV otherwise.
+func generateCreditCardCvv(rc rowContext) string {
+	if rc.fields.CreditCard != nil {
+		return rc.fields.CreditCard.Cvv
+	}
+
+	return rc.faker.CreditCardCvv()
+}
+
+// generateBool returns a random boolean using the true/false representation from the
+// -boolformat flag.
+func generateBool(rc rowContext) string {
+	trueValue, falseValue, _ := strings.Cut(rc.opts.BoolFormat, "/")
+	if rc.faker.Bool() {
+		return trueValue
+	}
+
+	return falseValue
+}
+
+// formatCoordinate formats a latitude or longitude to the number of decimal places set by
+// the -coordprecision flag. Both come from the row's shared address so they form a pair.
+func formatCoordinate(coordinate float64, opts Options) string {
+	return formatFloat(coordinate, opts.CoordPrecision, opts)
+}
+
+// formatID formats a value of the id field, zero padded to opts.IDWidth digits. An id
+// with more digits than that is written in full.
+func formatID(id int64, opts Options) string {
+	return fmt.Sprintf("%0*d", opts.IDWidth, id)
+}
+
+// formatInt formats the value of an integer field with opts.IntFormat, or as a plain
+// integer when no format is set.
+func formatInt(value int, opts Options) string {
+	if opts.IntFormat == "" {
+		return strconv.Itoa(value)
+	}
+
+	return fmt.Sprintf(opts.IntFormat, value)
+}
+
+// formatFloat formats the value of a decimal field. It's rounded to opts.Precision
+// decimal places when set, and to the field's own defaultPrecision otherwise.
+func formatFloat(value float64, defaultPrecision int, opts Options) string {
+	precision := defaultPrecision
+	if opts.Precision >= 0 {
+		precision = opts.Precision
+	}
+
+	return strconv.FormatFloat(value, 'f', precision, 64)
+}
+
+type BaseFields struct {
+	Name      string
+	FirstName string
+	LastName  string
+	Email     string
+	Username  string
+	Gender    string
+	Company   string
+	Job       *gofakeit.JobInfo
+	Address   *gofakeit.AddressInfo
+	Country   string
+	// Age and Birthdate are only set when both the age and birthdate fields are
+	// selected, so the age is the age of someone born on the birthdate.
+	Age       int
+	Birthdate time.Time
+	// Password is only set when both the password and passwordhash fields are selected.
+	Password string
+	// CreditCard is only set when the creditcard field is selected with the ccexpiry or
+	// cvv field.
+	CreditCard *gofakeit.CreditCardInfo
+}
+
+// Options holds the settings used to generate a CSV file, typically populated from
+// the command line flags. A Filename of "-" writes the CSV data to stdout. Start from
+// DefaultOptions, as the zero value of some settings isn't valid.
+type Options struct {
+	Rows           int64
+	MaxBytes       int64
+	MaxFields      int
+	Fields         string
+	Filename       string
+	OutputDir      string
+	DirPerm        string
+	Delimiter      string
+	Format         string
+	Widths         string
+	DateFormat     string
+	IntFormat      string
+	BoolFormat     string
+	CoordPrecision int
+	IDWidth        int
+	NullRate       float64
+	Workers        int
+	Retries        int
+	Gzip           bool
+	Append         bool
+	Overwrite      bool
+	Quiet          bool
+	AlwaysQuote    bool
+	CRLF           bool
+	BOM            bool
+	Comment        bool
+	ShuffleColumns bool
+	KeepFieldCase  bool
+	Static         bool
+	Verify         bool
+	KeepPartial    bool
+	Unique         string
+	EmailPrefix    string
+	Locale         string
+	Report         string
+	Manifest       string
+	Seed           int
+
+	// Precision is the number of decimal places in every decimal field, overriding
+	// CoordPrecision. When -1 each field keeps its own default.
+	Precision int
+	// AllowDuplicates allows a field to be selected more than once, giving a column for
+	// each time it's selected.
+	AllowDuplicates bool
+	// Log receives informational messages and progress updates, nil discards them.
+	Log io.Writer
+
+	// foreignKeys are the columns GenerateTables adds after the selected fields of a
+	// table that references another, and referenced is set for a table that another
+	// table references, so its ids are never empty.
+	foreignKeys []column
+	referenced  bool
+	// rowsWritten, when set, receives the number of rows written, which is only known
+	// once a run limited by MaxBytes is done.
+	rowsWritten *int64
+	// manifest, when set, collects every file written by a run for Manifest, shared by
+	// the tables generated by GenerateTables.
+	manifest *Manifest
+}
+
+// DefaultOptions returns the options used when a setting isn't given on the command
+// line: a single row of names and ages written to output/output.csv.
+func DefaultOptions() Options {
+	return Options{
+		Rows:           1,
+		MaxFields:      256,
+		Fields:         "name,age",
+		Filename:       "output.csv",
+		OutputDir:      "output",
+		DirPerm:        "0755",
+		Delimiter:      ",",
+		Format:         "csv",
+		Locale:         "en-US",
+		DateFormat:     "2006-01-02",
+		BoolFormat:     "true/false",
+		CoordPrecision: 6,
+		Precision:      -1,
+		Workers:        1,
+		Overwrite:      true,
+	}
+}
+
+// Generate validates opts and generates the requested file, or writes the data to stdout
+// when opts.Filename is "-".
+func Generate(opts Options) error {
+	return GenerateContext(context.Background(), opts)
+}
+
+// GenerateContext is like Generate, but stops generating rows once ctx is cancelled. The
+// rows written before then are kept and the returned error reports how many there were.
+func GenerateContext(ctx context.Context, opts Options) error {
+	opts, err := prepare(opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.Manifest != "" {
+		opts.manifest = &Manifest{}
+	}
+
+	if err := generate(ctx, OSFileHandler{}, CSVFileWriter{}, dataGenerators[opts.Format], opts); err != nil {
+		return err
+	}
+
+	return writeManifest(opts, OSFileHandler{})
+}
+
+// GenerateToWriter validates opts and writes the generated data to w instead of a file,
+// e.g. into a bytes.Buffer for an in-memory test fixture. Filename, OutputDir, Append,
+// Report and Manifest are ignored, and when opts.Gzip is set the data written to w is
+// compressed.
+func GenerateToWriter(w io.Writer, opts Options) (err error) {
+	opts, err = prepare(opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.Seed == 0 {
+		opts.Seed = randomSeed()
+	}
+	fmt.Fprintf(opts.logOutput(), "Seed: %d\n", opts.Seed)
+
+	var out io.WriteCloser = nopCloser{w}
+	if opts.Gzip {
+		out = gzipWriteCloser{Writer: gzip.NewWriter(w), file: out}
+	}
+	defer closeOutput(out, &err)
+
+	if err := dataGenerators[opts.Format].writeData(context.Background(), out, false, opts, CSVFileWriter{}); err != nil {
+		return fmt.Errorf("Failed to generate CSV data: %v", err)
+	}
+
+	return nil
+}
+
+// Preview validates opts and writes the header row and a single sample row to w, without
+// creating the output directory or file.
+func Preview(w io.Writer, opts Options) error {
+	opts, err := prepare(opts)
+	if err != nil {
+		return err
+	}
+
+	return dryRun(opts, w)
+}
+
+// Estimate validates opts and writes an estimate of the size of the output to w, measured
+// from a small sample of rows, without creating the output directory or file.
+func Estimate(w io.Writer, opts Options) error {
+	opts, err := prepare(opts)
+	if err != nil {
+		return err
+	}
+
+	return estimate(opts, w)
+}
+
+// CountOnly validates opts and generates the rows without writing them anywhere, then
+// writes the number of rows and the rate they were generated at to w. The rows are still
+// encoded in opts.Format, so only the cost of writing them to a file is left out.
+func CountOnly(w io.Writer, opts Options) error {
+	opts, err := prepare(opts)
+	if err != nil {
+		return err
+	}
+
+	return countOnly(opts, w)
+}
+
+// allFields selects every field that doesn't take parameters.
+const allFields = "all"
+
+// prepare validates opts and returns them ready to generate from, with ".gz" appended to
+// the filename when compressing and allFields expanded to the fields it selects.
+func prepare(opts Options) (Options, error) {
+	if err := validateFlags(opts); err != nil {
+		return opts, fmt.Errorf("Invalid options: %v", err)
+	}
+
+	if strings.EqualFold(opts.Fields, allFields) {
+		opts.Fields = strings.Join(validFieldNames(), ",")
+	}
+
+	// The header is written with the fields as they're selected, so they're put in their
+	// canonical case unless the header should keep the case they were given in.
+	if !opts.KeepFieldCase {
+		fields := splitFields(opts.Fields)
+		for i, field := range fields {
+			fields[i] = canonicalFieldCase(field)
+		}
+		opts.Fields = strings.Join(fields, ",")
+	}
+	opts.Unique = canonicalFieldCase(opts.Unique)
+
+	if opts.Gzip && opts.Filename != stdoutFilename && !strings.HasSuffix(opts.Filename, ".gz") {
+		opts.Filename += ".gz"
+	}
+
+	invalidFields := validateSelectedFields(opts.Fields)
+	if len(invalidFields) > 0 {
+		return opts, fmt.Errorf(
+			"Unable to generate CSV data. Invalid fields selected: %s. Valid fields are: %s",
+			strings.Join(invalidFields, ", "),
+			strings.Join(SupportedFields(), ", "),
+		)
+	}
+
+	if duplicates := duplicateFields(opts.Fields); len(duplicates) > 0 && !opts.AllowDuplicates {
+		return opts, fmt.Errorf("Unable to generate CSV data. Duplicate fields selected: %s", strings.Join(duplicates, ", "))
+	}
+
+	// The number of columns is only known once allFields is expanded.
+	if opts.Format == "fixed" {
+		widths, _ := parseWidths(opts.Widths)
+		if columns := len(splitFields(opts.Fields)) + len(opts.foreignKeys); len(widths) != columns {
+			return opts, fmt.Errorf("Invalid options: %d column widths given for %d columns", len(widths), columns)
+		}
+	}
+
+	// Without a number of rows there's nothing for the progress field to be relative to.
+	if opts.MaxBytes > 0 && slices.Contains(selectedFieldNames(opts.Fields), "progress") {
+		return opts, fmt.Errorf("Invalid options: the progress field can't be used with -maxbytes, as the number of rows isn't known")
+	}
+
+	if opts.ShuffleColumns {
+		// The seed is picked now rather than when generating so the printed seed
+		// reproduces the column order too.
+		if opts.Seed == 0 {
+			opts.Seed = randomSeed()
+		}
+		opts = shuffleColumns(opts)
+	}
+
+	return opts, nil
+}
+
+// shuffleColumns returns opts with the selected fields in an order drawn from opts.Seed,
+// and the widths of fixed width output in the same order. Foreign key columns stay
+// after the selected fields.
+func shuffleColumns(opts Options) Options {
+	fields := splitFields(opts.Fields)
+	order := rand.New(rand.NewPCG(uint64(opts.Seed), 0)).Perm(len(fields))
+
+	shuffled := make([]string, len(fields))
+	for i, j := range order {
+		shuffled[i] = fields[j]
+	}
+	opts.Fields = strings.Join(shuffled, ",")
+
+	if opts.Format == "fixed" {
+		widths := strings.Split(opts.Widths, ",")
+		shuffledWidths := make([]string, len(widths))
+		for i, j := range order {
+			shuffledWidths[i] = widths[j]
+		}
+		opts.Widths = strings.Join(shuffledWidths, ",")
+	}
+
+	return opts
+}
+
+// logOutput returns the writer informational messages should be written to.
+func (o Options) logOutput() io.Writer {
+	if o.Log == nil {
+		return io.Discard
+	}
+
+	return o.Log
+}
+
+// delimiterRune returns the rune the CSV writer should separate fields with. The
+// two character sequence `\t` is accepted as a tab since it's awkward to pass a
+// literal tab on most shells.
+func (o Options) delimiterRune() rune {
+	if o.Delimiter == `\t` {
+		return '\t'
+	}
+
+	r, _ := utf8.DecodeRuneInString(o.Delimiter)
+	return r
+}
+
+// validateFilename makes sure the filename names a file directly inside the output
+// directory, so it can't be used to write outside of it. Both '/' and '\' are rejected
+// so the same filename is safe on every platform.
+func validateFilename(filename string) error {
+	if strings.ContainsAny(filename, `/\`) {
+		return fmt.Errorf("filename cannot contain path separators: %q", filename)
+	}
+
+	if filename == "." || filename == ".." {
+		return fmt.Errorf("invalid filename: %q", filename)
+	}
+
+	return nil
+}
+
+func validateFlags(opts Options) error {
+	if opts.Rows <= 0 {
+		return fmt.Errorf("invalid number of rows: %d", opts.Rows)
+	}
+
+	if opts.MaxBytes < 0 {
+		return fmt.Errorf("invalid maximum number of bytes: %d", opts.MaxBytes)
+	}
+
+	if opts.Fields == "" {
+		return fmt.Errorf("fields cannot be empty")
+	}
+
+	if opts.MaxFields <= 0 {
+		return fmt.Errorf("invalid maximum number of fields: %d", opts.MaxFields)
+	}
+
+	// Every selected field adds a column to every row, so a huge selection is more likely
+	// a mistake than a file anyone wants.
+	fieldCount := len(splitFields(opts.Fields))
+	if strings.EqualFold(opts.Fields, allFields) {
+		fieldCount = len(validFieldNames())
+	}
+	if fieldCount > opts.MaxFields {
+		return fmt.Errorf("%d fields selected, more than the maximum of %d", fieldCount, opts.MaxFields)
+	}
+
+	if opts.Filename == "" {
+		return fmt.Errorf("filename cannot be empty")
+	}
+
+	if err := validateFilename(opts.Filename); err != nil {
+		return err
+	}
+
+	if opts.OutputDir == "" {
+		return fmt.Errorf("output directory cannot be empty")
+	}
+
+	if _, err := parseDirPerm(opts.DirPerm); err != nil {
+		return err
+	}
+
+	if opts.Delimiter != `\t` && utf8.RuneCountInString(opts.Delimiter) != 1 {
+		return fmt.Errorf("delimiter must be a single character: %q", opts.Delimiter)
+	}
+
+	// A quote or line break as the delimiter can't be told apart from the quoting and
+	// the end of a row.
+	if opts.Delimiter == `"` || opts.Delimiter == "\r" || opts.Delimiter == "\n" {
+		return fmt.Errorf("delimiter can't be a quote or line break: %q", opts.Delimiter)
+	}
+
+	// A format that doesn't take a single integer, such as "%s" or "%d-%d", leaves an
+	// error like %!s(int=1) in the formatted value.
+	if opts.IntFormat != "" && strings.Contains(fmt.Sprintf(opts.IntFormat, 1), "%!") {
+		return fmt.Errorf("integer format must format a single integer: %q", opts.IntFormat)
+	}
+
+	if opts.DateFormat == "" {
+		return fmt.Errorf("date format cannot be empty")
+	}
+
+	if trueValue, falseValue, ok := strings.Cut(opts.BoolFormat, "/"); !ok || trueValue == "" || falseValue == "" || trueValue == falseValue {
+		return fmt.Errorf("boolean format must be two different values separated by '/': %q", opts.BoolFormat)
+	}
+
+	if opts.CoordPrecision < 0 {
+		return fmt.Errorf("invalid coordinate precision: %d", opts.CoordPrecision)
+	}
+
+	if opts.Precision < -1 {
+		return fmt.Errorf("invalid precision: %d", opts.Precision)
+	}
+
+	if opts.NullRate < 0 || opts.NullRate > 1 {
+		return fmt.Errorf("null rate must be between 0 and 1: %v", opts.NullRate)
+	}
+
+	if opts.Workers <= 0 {
+		return fmt.Errorf("invalid number of workers: %d", opts.Workers)
+	}
+
+	if opts.Retries < 0 {
+		return fmt.Errorf("invalid number of retries: %d", opts.Retries)
+	}
+
+	if opts.IDWidth < 0 {
+		return fmt.Errorf("invalid id width: %d", opts.IDWidth)
+	}
+
+	if _, ok := dataGenerators[opts.Format]; !ok {
+		return fmt.Errorf("invalid format: %q", opts.Format)
+	}
+
+	if opts.Format == "fixed" {
+		if _, err := parseWidths(opts.Widths); err != nil {
+			return err
+		}
+	} else if opts.Widths != "" {
+		return fmt.Errorf("column widths are only supported for fixed width output")
+	}
+
+	if strings.ContainsAny(opts.EmailPrefix, "@ \t\r\n") {
+		return fmt.Errorf("email prefix can't contain '@' or whitespace: %q", opts.EmailPrefix)
+	}
+
+	if opts.Static && opts.Unique != "" {
+		return fmt.Errorf("static rows can't have a unique field, as every row is the same")
+	}
+
+	if opts.BOM && opts.Format != "csv" {
+		return fmt.Errorf("a byte order mark is only supported for csv output")
+	}
+
+	if opts.Comment && opts.Format != "csv" {
+		return fmt.Errorf("a comment line is only supported for csv output")
+	}
+
+	if opts.Verify && opts.Format != "csv" {
+		return fmt.Errorf("verification is only supported for csv output")
+	}
+
+	if opts.Verify && opts.Filename == stdoutFilename {
+		return fmt.Errorf("verification can't be used when writing to stdout")
+	}
+
+	if !supportedLocales[opts.Locale] {
+		return fmt.Errorf("unsupported locale %q, supported locales are: %s", opts.Locale, strings.Join(supportedLocaleNames(), ", "))
+	}
+
+	return nil
+}
+
+// supportedLocales are the locales data can be generated for. gofakeit only has US
+// English data, so that's the only locale until localized data is available.
+var supportedLocales = map[string]bool{
+	"en-US": true,
+}
+
+// supportedLocaleNames returns the supported locales in alphabetical order.
+func supportedLocaleNames() []string {
+	locales := []string{}
+	for locale := range supportedLocales {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+
+	return locales
+}
+
+// validFieldNames returns the names of all supported fields in alphabetical order.
+func validFieldNames() []string {
+	names := make([]string, 0, len(validFields))
+	for name := range validFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// SupportedFields returns every supported field, including the usage of fields that
+// take parameters, in alphabetical order.
+func SupportedFields() []string {
+	names := validFieldNames()
+	for _, field := range parameterizedFields {
+		names = append(names, field.usage)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// validateSelectedFields returns the selected fields that can't be generated. Fields
+// with invalid parameters are returned along with the reason they're invalid.
+func validateSelectedFields(fields string) []string {
+	var invalidFields []string
+	for _, userField := range splitFields(fields) {
+		if _, err := parseColumn(userField); errors.Is(err, errUnknownField) {
+			invalidFields = append(invalidFields, userField)
+		} else if err != nil {
+			invalidFields = append(invalidFields, fmt.Sprintf("%s (%v)", userField, err))
+		}
+	}
+
+	return invalidFields
+}
+
+// selectedFieldNames returns the names of the fields a valid list of fields generates
+// values from, including the fields a template calls.
+func selectedFieldNames(fields string) []string {
+	columns, err := parseColumns(fields)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, col := range columns {
+		names = append(names, col.fieldNames()...)
+	}
+
+	return names
+}
+
+// duplicateFields returns the fields that are selected more than once, each listed once
+// in the order they were selected. Seed and empty rate annotations are ignored, as the
+// field is still selected again.
+func duplicateFields(fields string) []string {
+	var duplicates []string
+	counts := map[string]int{}
+	for _, userField := range splitFields(fields) {
+		if field, _, err := cutSeed(userField); err == nil {
+			userField = field
+		}
+		if field, _, _, err := cutEmptyRate(userField); err == nil {
+			userField = field
+		}
+		if field, _, err := cutTransform(userField); err == nil {
+			userField = field
+		}
+
+		// Fields selected in a different case are still the same field.
+		key := canonicalFieldCase(userField)
+		counts[key]++
+		if counts[key] == 2 {
+			duplicates = append(duplicates, userField)
+		}
+	}
+
+	return duplicates
+}
+
+// parameterizedField is a field that takes parameters, such as int(1,1000).
+type parameterizedField struct {
+	usage string
+	// newGenerator builds the generator for the field from the text between the
+	// parentheses, returning an error if the parameters are invalid.
+	newGenerator func(params string) (fieldGenerator, error)
+	// references returns the other fields the field's values are built from, if any.
+	references func(params string) []string
+}
+
+var parameterizedFields = map[string]parameterizedField{
+	"int":       {usage: "int(min,max)", newGenerator: newIntGenerator},
+	"price":     {usage: "price(min,max)", newGenerator: newPriceGenerator},
+	"salary":    {usage: "salary(min,max)", newGenerator: newSalaryGenerator},
+	"sentence":  {usage: "sentence(words)", newGenerator: newSentenceGenerator},
+	"paragraph": {usage: "paragraph(sentences)", newGenerator: newParagraphGenerator},
+	"enum":      {usage: "enum(option:weight,...)", newGenerator: newEnumGenerator},
+	"timestamp": {usage: "timestamp(start,end)", newGenerator: newTimestampGenerator},
+	"fromfile":  {usage: "fromfile(path)", newGenerator: newFromFileGenerator},
+	"template":  {usage: "template(text)", newGenerator: newTemplateGenerator, references: templateReferences},
+}
+
+var errUnknownField = errors.New("unknown field")
+
+// canonicalFieldNames maps the lowercased name of every field, including those that take
+// parameters, to its canonical name, so fields can be selected in any case.
+var canonicalFieldNames = func() map[string]string {
+	names := map[string]string{}
+	for name := range generators {
+		names[strings.ToLower(name)] = name
+	}
+	for name := range parameterizedFields {
+		names[strings.ToLower(name)] = name
+	}
+
+	return names
+}()
+
+// canonicalFieldCase returns a selected field with its name in its canonical case, e.g.
+// "firstName?0.5" for "FIRSTNAME?0.5". Parameters and annotations are left as they are,
+// and unknown fields are returned unchanged.
+func canonicalFieldCase(userField string) string {
+	end := strings.IndexAny(userField, "(:?@")
+	if end == -1 {
+		end = len(userField)
+	}
+
+	name, ok := canonicalFieldNames[strings.ToLower(userField[:end])]
+	if !ok {
+		return userField
+	}
+
+	return name + userField[end:]
+}
+
+// column is a selected field and the generator used for its values.
+type column struct {
+	// name is the canonical name of the column's field, and header the name written in
+	// the header row, which keeps the case the field was selected in.
+	name     string
+	header   string
+	generate fieldGenerator
+	// notNull columns are never replaced with an empty value, which is used for the
+	// keys relating tables generated by GenerateTables.
+	notNull bool
+	// seed is the column's own seed, given as field@seed, or 0 when the column draws
+	// from the row generator's faker like the other columns.
+	seed int
+	// emptyRate is the rate at which the column's values are replaced with an empty
+	// value, given as field?rate, used instead of opts.NullRate when hasEmptyRate is set.
+	emptyRate    float64
+	hasEmptyRate bool
+	// references are the other fields the column's values are built from, such as the
+	// fields used by a template.
+	references []string
+}
+
+// nullRate returns the rate at which the column's values are replaced with an empty value.
+func (c column) nullRate(opts Options) float64 {
+	switch {
+	case c.notNull:
+		return 0
+	case c.hasEmptyRate:
+		return c.emptyRate
+	default:
+		return opts.NullRate
+	}
+}
+
+// fieldNames returns the name of the column followed by the fields it references.
+func (c column) fieldNames() []string {
+	return append([]string{c.name}, c.references...)
+}
+
+// splitFields splits a comma separated list of fields, ignoring commas between the
+// parentheses of a parameterized field.
+func splitFields(fields string) []string {
+	var fieldSlice []string
+	depth, start := 0, 0
+	for i, r := range fields {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case r == ',' && depth == 0:
+			fieldSlice = append(fieldSlice, fields[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(fieldSlice, fields[start:])
+}
+
+// cutSeed splits a field@seed annotation into the field and its seed, returning a seed of
+// 0 when the field isn't annotated. An @ between the parentheses of a parameterized field
+// is part of its parameters.
+func cutSeed(userField string) (string, int, error) {
+	i := strings.LastIndex(userField, "@")
+	if i == -1 || strings.Contains(userField[i:], ")") {
+		return userField, 0, nil
+	}
+
+	seedParam := userField[i+1:]
+	seed, err := strconv.Atoi(seedParam)
+	if err != nil || seed <= 0 {
+		return "", 0, fmt.Errorf("seed must be a positive integer: %q", seedParam)
+	}
+
+	return userField[:i], seed, nil
+}
+
+// cutEmptyRate splits a field?rate annotation into the field and the rate at which its
+// values are empty, reporting whether the field is annotated. A ? between the parentheses
+// of a parameterized field is part of its parameters.
+func cutEmptyRate(userField string) (string, float64, bool, error) {
+	i := strings.LastIndex(userField, "?")
+	if i == -1 || strings.Contains(userField[i:], ")") {
+		return userField, 0, false, nil
+	}
+
+	rateParam := userField[i+1:]
+	rate, err := strconv.ParseFloat(rateParam, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return "", 0, false, fmt.Errorf("empty rate must be between 0 and 1: %q", rateParam)
+	}
+
+	return userField[:i], rate, true, nil
+}
+
+// fieldTransforms are the transforms a field's values can be passed through, given as
+// field:transform.
+var fieldTransforms = map[string]func(string) string{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"title": titleCase,
+}
+
+// fieldTransformNames returns the names of the fieldTransforms in alphabetical order.
+func fieldTransformNames() []string {
+	names := []string{}
+	for name := range fieldTransforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// titleCase returns s with the first letter of each word in upper case and the other
+// letters in lower case, e.g. "Jane Doe" for "jANE DOE".
+func titleCase(s string) string {
+	var b strings.Builder
+	startOfWord := true
+	for _, r := range s {
+		if startOfWord {
+			b.WriteRune(unicode.ToUpper(r))
+		} else {
+			b.WriteRune(unicode.ToLower(r))
+		}
+		startOfWord = unicode.IsSpace(r)
+	}
+
+	return b.String()
+}
+
+// cutTransform splits a field:transform annotation into the field and the transform of
+// its values, returning a nil transform when the field isn't annotated. A : between the
+// parentheses of a parameterized field is part of its parameters.
+func cutTransform(userField string) (string, func(string) string, error) {
+	i := strings.LastIndex(userField, ":")
+	if i == -1 || strings.Contains(userField[i:], ")") {
+		return userField, nil, nil
+	}
+
+	transform, ok := fieldTransforms[userField[i+1:]]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown transform %q, supported transforms are: %s", userField[i+1:], strings.Join(fieldTransformNames(), ", "))
+	}
+
+	return userField[:i], transform, nil
+}
+
+// parseColumn returns the column for a single selected field, which can be annotated
+// with a transform, then an empty rate and then a seed, as in field:transform?rate@seed.
+func parseColumn(userField string) (column, error) {
+	userField, seed, err := cutSeed(userField)
+	if err != nil {
+		return column{}, err
+	}
+
+	userField, emptyRate, hasEmptyRate, err := cutEmptyRate(userField)
+	if err != nil {
+		return column{}, err
+	}
+
+	userField, transform, err := cutTransform(userField)
+	if err != nil {
+		return column{}, err
+	}
+
+	col, err := parseUnseededColumn(userField)
+	col.seed = seed
+	col.emptyRate = emptyRate
+	col.hasEmptyRate = hasEmptyRate
+
+	if transform != nil && err == nil {
+		generate := col.generate
+		col.generate = func(rc rowContext) string { return transform(generate(rc)) }
+	}
+
+	return col, err
+}
+
+// parseUnseededColumn returns the column for a single selected field without a seed
+// annotation.
+func parseUnseededColumn(userField string) (column, error) {
+	header := userField
+	userField = canonicalFieldCase(userField)
+
+	name, params, hasParams := strings.Cut(userField, "(")
+	if !hasParams {
+		generate, ok := generators[userField]
+		if !ok {
+			return column{}, errUnknownField
+		}
+
+		return column{name: userField, header: header, generate: generate}, nil
+	}
+
+	field, ok := parameterizedFields[name]
+	if !ok || !strings.HasSuffix(params, ")") {
+		return column{}, errUnknownField
+	}
+
+	params = strings.TrimSuffix(params, ")")
+	generate, err := field.newGenerator(params)
+	if err != nil {
+		return column{}, err
+	}
+
+	col := column{name: userField, header: header, generate: generate}
+	if field.references != nil {
+		col.references = field.references(params)
+	}
+
+	return col, nil
+}
+
+// parseColumns returns the columns for a comma separated list of fields.
+func parseColumns(fields string) ([]column, error) {
+	var columns []column
+	for _, userField := range splitFields(fields) {
+		col, err := parseColumn(userField)
+		if err != nil {
+			return nil, fmt.Errorf("invalid field %s: %v", userField, err)
+		}
+		columns = append(columns, col)
+	}
+
+	return columns, nil
+}
+
+// columnNames returns the header row for columns.
+func columnNames(columns []column) []string {
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.name
+		if col.header != "" {
+			names[i] = col.header
+		}
+	}
+
+	return names
+}
+
+// parseRange parses the "min,max" parameters used by numeric range fields.
+func parseRange(params string) (int, int, error) {
+	minParam, maxParam, ok := strings.Cut(params, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected min and max separated by a comma")
+	}
+
+	minValue, err := strconv.Atoi(strings.TrimSpace(minParam))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid min %q", minParam)
+	}
+
+	maxValue, err := strconv.Atoi(strings.TrimSpace(maxParam))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid max %q", maxParam)
+	}
+
+	if minValue > maxValue {
+		return 0, 0, fmt.Errorf("min %d is greater than max %d", minValue, maxValue)
+	}
+
+	// The number of values in the range has to fit in an int to draw one of them. The
+	// difference is exact as a uint, as max isn't less than min.
+	if uint(maxValue)-uint(minValue) >= math.MaxInt {
+		return 0, 0, fmt.Errorf("range is too wide")
+	}
+
+	return minValue, maxValue, nil
+}
+
+// parseFloatRange parses the "min,max" parameters used by decimal range fields.
+func parseFloatRange(params string) (float64, float64, error) {
+	minParam, maxParam, ok := strings.Cut(params, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected min and max separated by a comma")
+	}
+
+	minValue, err := strconv.ParseFloat(strings.TrimSpace(minParam), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid min %q", minParam)
+	}
+
+	maxValue, err := strconv.ParseFloat(strings.TrimSpace(maxParam), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid max %q", maxParam)
+	}
+
+	if minValue > maxValue {
+		return 0, 0, fmt.Errorf("min %v is greater than max %v", minValue, maxValue)
+	}
+
+	return minValue, maxValue, nil
+}
+
+// newIntGenerator returns a generator for int(min,max), a random integer between min and
+// max inclusive.
+func newIntGenerator(params string) (fieldGenerator, error) {
+	minValue, maxValue, err := parseRange(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(rc rowContext) string { return formatInt(rc.faker.Number(minValue, maxValue), rc.opts) }, nil
+}
+
+// parseCount parses the single positive count parameter used by text fields.
+func parseCount(params string) (int, error) {
+	count, err := strconv.Atoi(strings.TrimSpace(params))
+	if err != nil {
+		return 0, fmt.Errorf("invalid count %q", params)
+	}
+
+	if count <= 0 {
+		return 0, fmt.Errorf("count must be positive: %d", count)
+	}
+
+	return count, nil
+}
+
+// The lengths of the sentence and paragraph fields when no length is given.
+const (
+	defaultSentenceWords      = 10
+	defaultParagraphSentences = 3
+)
+
+// newSentenceGenerator returns a generator for sentence(words), a sentence with the given
+// number of words.
+func newSentenceGenerator(params string) (fieldGenerator, error) {
+	words, err := parseCount(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(rc rowContext) string { return rc.faker.Sentence(words) }, nil
+}
+
+// newParagraphGenerator returns a generator for paragraph(sentences), a paragraph with
+// the given number of sentences.
+func newParagraphGenerator(params string) (fieldGenerator, error) {
+	sentences, err := parseCount(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(rc rowContext) string { return generateParagraph(rc, sentences) }, nil
+}
+
+// generateParagraph returns a single paragraph of sentences with the default number of
+// words.
+func generateParagraph(rc rowContext, sentences int) string {
+	return rc.faker.Paragraph(1, sentences, defaultSentenceWords, "")
+}
+
+// newEnumGenerator returns a generator for enum(option:weight,...), one of the options
+// picked with a probability proportional to its weight. An option without a weight has a
+// weight of 1, so enum(a,b,c) picks each option equally often.
+func newEnumGenerator(params string) (fieldGenerator, error) {
+	options := []any{}
+	weights := []float32{}
+	var total float32
+	for _, param := range strings.Split(params, ",") {
+		option, weightParam, hasWeight := strings.Cut(strings.TrimSpace(param), ":")
+		if option == "" {
+			return nil, fmt.Errorf("options cannot be empty")
+		}
+
+		weight := 1.0
+		if hasWeight {
+			var err error
+			weight, err = strconv.ParseFloat(strings.TrimSpace(weightParam), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid weight %q for option %q", weightParam, option)
+			}
+			if weight <= 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+				return nil, fmt.Errorf("weight for option %q must be a positive number: %v", option, weight)
+			}
+		}
+
+		// Weighted takes float32 weights, which can't hold every positive float64 and are
+		// summed as float32 to pick an option.
+		narrowed := float32(weight)
+		if narrowed == 0 || math.IsInf(float64(narrowed), 0) {
+			return nil, fmt.Errorf("weight for option %q is out of range: %v", option, weight)
+		}
+		if total += narrowed; math.IsInf(float64(total), 0) {
+			return nil, fmt.Errorf("weights must add up to at most %v", math.MaxFloat32)
+		}
+
+		options = append(options, option)
+		weights = append(weights, narrowed)
+	}
+
+	return func(rc rowContext) string {
+		// Weighted only fails for empty or mismatched options and weights, ruled out above.
+		option, _ := rc.faker.Weighted(options, weights)
+		return option.(string)
+	}, nil
+}
+
+// newFromFileGenerator returns a generator for fromfile(path), a random line of the file at
+// path. The file is read once, when the field is parsed, and blank lines and the space
+// around each line are ignored. A line repeated in the file is picked more often.
+func newFromFileGenerator(params string) (fieldGenerator, error) {
+	path := strings.TrimSpace(params)
+	if path == "" {
+		return nil, fmt.Errorf("path cannot be empty")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read values: %v", err)
+	}
+
+	var values []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if value := strings.TrimSpace(line); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("file %s has no values", path)
+	}
+
+	return func(rc rowContext) string { return rc.faker.RandomString(values) }, nil
+}
+
+// newTemplateGenerator returns a generator for template(text), a Go text/template calling
+// other fields by name, e.g. template({{firstName}} <{{email}}>). Fields read from
+// BaseFields have the same values as the row's own columns, other fields are drawn again
+// for the template. Referencing a field that doesn't exist fails when the template is
+// parsed, and so does a template that fails to execute on a sample row, as a row the
+// template fails on is written as an empty value.
+func newTemplateGenerator(params string) (fieldGenerator, error) {
+	tmpl, err := template.New("template").Funcs(templateFuncs(&rowContext{})).Parse(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// The sample row has every BaseField set, so any field the template calls has a value.
+	faker := gofakeit.New(1)
+	options := baseFieldOptions{withGender: true, withCompany: true, withJob: true, withBirthdate: true, withPassword: true, withCreditCard: true}
+	sample := rowContext{faker: faker, fields: generateBaseFields(faker, options), opts: DefaultOptions(), hasAddress: true, currency: faker.Currency()}
+	generatePairedFields(faker, options, &sample.fields)
+	if err := template.Must(tmpl.Clone()).Funcs(templateFuncs(&sample)).Execute(io.Discard, nil); err != nil {
+		return nil, err
+	}
+
+	// The functions of a template can't be changed while it is being executed, so each
+	// worker takes its own copy from the pool whose functions read the row it sets.
+	type rowTemplate struct {
+		tmpl *template.Template
+		rc   rowContext
+	}
+	pool := sync.Pool{New: func() any {
+		rt := &rowTemplate{}
+		rt.tmpl = template.Must(tmpl.Clone()).Funcs(templateFuncs(&rt.rc))
+		return rt
+	}}
+
+	return func(rc rowContext) string {
+		rt := pool.Get().(*rowTemplate)
+		defer pool.Put(rt)
+
+		rt.rc = rc
+		var b strings.Builder
+		if err := rt.tmpl.Execute(&b, nil); err != nil {
+			return ""
+		}
+		return b.String()
+	}, nil
+}
+
+// templateFuncs returns a template function for each field that isn't parameterized,
+// generating the field's value for the row rc points to.
+func templateFuncs(rc *rowContext) template.FuncMap {
+	funcs := template.FuncMap{}
+	for name, generate := range generators {
+		funcs[name] = func() string { return generate(*rc) }
+	}
+
+	return funcs
+}
+
+// templateReferences returns the fields called by a template, in the order they are
+// first called in the template and then in the templates it defines, by name.
+func templateReferences(params string) []string {
+	tmpl, err := template.New("template").Funcs(templateFuncs(&rowContext{})).Parse(params)
+	if err != nil {
+		return nil
+	}
+
+	var references []string
+	seen := map[string]bool{}
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch node := node.(type) {
+		case *parse.ListNode:
+			if node == nil {
+				return
+			}
+			for _, n := range node.Nodes {
+				walk(n)
+			}
+		case *parse.ActionNode:
+			walk(node.Pipe)
+		case *parse.PipeNode:
+			if node == nil {
+				return
+			}
+			for _, cmd := range node.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range node.Args {
+				walk(arg)
+			}
+		case *parse.IfNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.RangeNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.WithNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.IdentifierNode:
+			if _, ok := generators[node.Ident]; ok && !seen[node.Ident] {
+				seen[node.Ident] = true
+				references = append(references, node.Ident)
+			}
+		}
+	}
+	walk(tmpl.Tree.Root)
+
+	// Fields called in a {{define}} or {{block}} body are only reached through the
+	// template it's called by, so every template's tree is walked.
+	defined := tmpl.Templates()
+	sort.Slice(defined, func(i, j int) bool { return defined[i].Name() < defined[j].Name() })
+	for _, t := range defined {
+		if t != tmpl && t.Tree != nil {
+			walk(t.Tree.Root)
+		}
+	}
+
+	return references
+}
+
+// parseTimestamp parses a timestamp parameter, either a date such as 2024-12-31 or an
+// RFC 3339 timestamp. A date used as the end of a range includes the whole day.
+func parseTimestamp(param string, isEnd bool) (time.Time, error) {
+	param = strings.TrimSpace(param)
+	if t, err := time.Parse(time.DateOnly, param); err == nil {
+		if isEnd {
+			t = t.Add(24*time.Hour - time.Nanosecond)
+		}
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, param)
+}
+
+// The range of timestamps DateRange supports, as it works with nanoseconds since the
+// Unix epoch in an int64.
+var (
+	minTimestamp = time.Unix(0, math.MinInt64)
+	maxTimestamp = time.Unix(0, math.MaxInt64)
+)
+
+// newTimestampGenerator returns a generator for timestamp(start,end), a random RFC 3339
+// timestamp in UTC between start and end.
+func newTimestampGenerator(params string) (fieldGenerator, error) {
+	startParam, endParam, ok := strings.Cut(params, ",")
+	if !ok {
+		return nil, fmt.Errorf("expected start and end separated by a comma")
+	}
+
+	start, err := parseTimestamp(startParam, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start %q", startParam)
+	}
+
+	end, err := parseTimestamp(endParam, true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end %q", endParam)
+	}
+
+	if start.After(end) {
+		return nil, fmt.Errorf("start %q is after end %q", startParam, endParam)
+	}
+
+	if start.Before(minTimestamp) || end.After(maxTimestamp) {
+		return nil, fmt.Errorf("timestamps must be between %s and %s", minTimestamp.UTC().Format(time.DateOnly), maxTimestamp.UTC().Format(time.DateOnly))
+	}
+
+	return func(rc rowContext) string { return rc.faker.DateRange(start, end).Format(time.RFC3339) }, nil
+}
+
+// The range used by the price field when no range is given.
+const (
+	defaultMinPrice = 1
+	defaultMaxPrice = 1000
+)
+
+// newPriceGenerator returns a generator for price(min,max), a random price between min and
+// max.
+func newPriceGenerator(params string) (fieldGenerator, error) {
+	minValue, maxValue, err := parseFloatRange(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(rc rowContext) string { return formatPrice(rc.faker.Price(minValue, maxValue), rc.opts) }, nil
+}
+
+// The range used by the salary field when no range is given.
+const (
+	defaultMinSalary = 30_000
+	defaultMaxSalary = 200_000
+)
+
+// newSalaryGenerator returns a generator for salary(min,max), a whole salary between min
+// and max skewed towards min.
+func newSalaryGenerator(params string) (fieldGenerator, error) {
+	minValue, maxValue, err := parseRange(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(rc rowContext) string { return formatInt(skewedNumber(rc.faker, minValue, maxValue), rc.opts) }, nil
+}
+
+// skewedNumber returns a random integer between min and max inclusive, skewed towards min
+// the way incomes are. The square of a uniform value is used, so half of the values fall
+// in the lowest quarter of the range and few are near max.
+func skewedNumber(f *gofakeit.Faker, minValue int, maxValue int) int {
+	// The width of the range is computed as a float64, as it overflows an int for ranges
+	// wider than math.MaxInt.
+	u := f.Float64()
+	offset := u * u * (float64(maxValue) - float64(minValue) + 1)
+	if offset >= float64(maxValue)-float64(minValue) {
+		return maxValue
+	}
+
+	// The offset is less than the width, which fits in a uint64, and adding it wraps
+	// around to a value between min and max.
+	return minValue + int(uint64(offset))
+}
+
+// pricePrecision is the default number of decimal places in a price.
+const pricePrecision = 2
+
+// formatPrice formats a price to two decimal places, unless opts.Precision is set.
+func formatPrice(price float64, opts Options) string {
+	return formatFloat(price, pricePrecision, opts)
+}
+
+// addressCountry is the country of the addresses generated by gofakeit, which always
+// have a US state and zip code. AddressInfo.Country is drawn independently of the rest of
+// the address so it isn't used.
+const addressCountry = "United States"
+
+// generateCompany returns the company the row's email domain and url were derived from
+// when either is also selected, or a random company otherwise.
+func generateCompany(rc rowContext) string {
+	if rc.fields.Company != "" {
+		return rc.fields.Company
+	}
+
+	return rc.faker.Company()
+}
+
+// generateURL returns the website of the row's company when company is also selected, or
+// a random URL otherwise.
+func generateURL(rc rowContext) string {
+	if rc.fields.Company != "" {
+		return "https://www." + companyDomain(rc.fields.Company)
+	}
+
+	return rc.faker.URL()
+}
+
+// The range of the age field.
+const (
+	minAge = 18
+	maxAge = 99
+)
+
+// generateAge returns the age of someone born on the row's birthdate when the birthdate
+// field is also selected, or a random age otherwise.
+func generateAge(rc rowContext) string {
+	if !rc.fields.Birthdate.IsZero() {
+		return formatInt(rc.fields.Age, rc.opts)
+	}
+
+	return formatInt(rc.faker.Number(minAge, maxAge), rc.opts)
+}
+
+// generateBirthdate returns the row's birthdate when the age field is also selected, so
+// the two agree, or a random date otherwise.
+func generateBirthdate(rc rowContext) string {
+	if !rc.fields.Birthdate.IsZero() {
+		return rc.fields.Birthdate.Format(rc.opts.DateFormat)
+	}
+
+	return rc.faker.Date().Format(rc.opts.DateFormat)
+}
+
+// generateCountry returns the country of the row's address when any address fields are
+// selected, so the location fields agree, or a random country otherwise.
+func generateCountry(rc rowContext) string {
+	if rc.hasAddress {
+		return rc.fields.Country
+	}
+
+	return rc.faker.Country()
+}
+
+// generateFood returns a dish from one of gofakeit's breakfast, lunch, dinner and snack
+// lists, as it has no function for food in general.
+func generateFood(rc rowContext) string {
+	switch rc.faker.IntN(4) {
+	case 0:
+		return rc.faker.Breakfast()
+	case 1:
+		return rc.faker.Lunch()
+	case 2:
+		return rc.faker.Dinner()
+	default:
+		return rc.faker.Snack()
+	}
+}
+
+// progressPrecision is the number of decimal places of the progress field.
+const progressPrecision = 2
+
+// generateJSONBlob returns a small JSON object for testing columns that embed JSON, e.g.
+// {"count":42,"enabled":true,"tag":"lamp"}. The csv.Writer quotes it and doubles its
+// quotes.
+func generateJSONBlob(rc rowContext) string {
+	// The values are drawn in a fixed order, as ranging over a map isn't.
+	count := rc.faker.Number(1, 100)
+	enabled := rc.faker.Bool()
+	tag := rc.faker.Noun()
+
+	// Marshal only fails for values that can't be encoded, and sorts the keys.
+	data, _ := json.Marshal(map[string]any{"count": count, "enabled": enabled, "tag": tag})
+	return string(data)
+}
+
+// generateProgress returns how far through the run the row is as a percentage of
+// opts.Rows, from 100/rows% for the first row to 100% for the last.
+func generateProgress(rc rowContext) string {
+	percent := float64(rc.row+1) / float64(rc.opts.Rows) * 100
+	return formatFloat(percent, progressPrecision, rc.opts) + "%"
+}
+
+// httpStatusCodes are the values of the httpstatus field and their weights, roughly the
+// mix of responses in a web server's access log: mostly 200, some 404 and few 500.
+var httpStatusCodes = []struct {
+	code   string
+	weight float32
+}{
+	{"200", 70},
+	{"201", 5},
+	{"204", 3},
+	{"301", 1},
+	{"302", 2},
+	{"304", 4},
+	{"400", 3},
+	{"401", 2},
+	{"403", 2},
+	{"404", 5},
+	{"429", 1},
+	{"500", 1.5},
+	{"502", 0.3},
+	{"503", 0.2},
+}
+
+// httpStatusOptions and httpStatusWeights are httpStatusCodes in the form Weighted takes.
+var httpStatusOptions, httpStatusWeights = func() ([]any, []float32) {
+	options := make([]any, len(httpStatusCodes))
+	weights := make([]float32, len(httpStatusCodes))
+	for i, status := range httpStatusCodes {
+		options[i] = status.code
+		weights[i] = status.weight
+	}
+
+	return options, weights
+}()
+
+// generateHTTPStatus returns one of httpStatusCodes, picked in proportion to its weight.
+func generateHTTPStatus(rc rowContext) string {
+	// Weighted only fails for empty or mismatched options and weights.
+	status, _ := rc.faker.Weighted(httpStatusOptions, httpStatusWeights)
+	return status.(string)
+}
+
+// usTimeZone is one of the time zones of the US states, named like gofakeit's time zones.
+type usTimeZone struct {
+	name string
+	abbr string
+}
+
+var (
+	easternTimeZone  = usTimeZone{name: "Eastern Standard Time", abbr: "EST"}
+	centralTimeZone  = usTimeZone{name: "Central Standard Time", abbr: "CST"}
+	mountainTimeZone = usTimeZone{name: "Mountain Standard Time", abbr: "MST"}
+	arizonaTimeZone  = usTimeZone{name: "US Mountain Standard Time", abbr: "MST"}
+	pacificTimeZone  = usTimeZone{name: "Pacific Standard Time", abbr: "PST"}
+	alaskanTimeZone  = usTimeZone{name: "Alaskan Standard Time", abbr: "AKST"}
+	hawaiianTimeZone = usTimeZone{name: "Hawaiian Standard Time", abbr: "HST"}
+)
+
+// stateTimeZones maps each state gofakeit generates to the time zone most of the state
+// observes.
+var stateTimeZones = map[string]usTimeZone{
+	"Alabama": centralTimeZone, "Alaska": alaskanTimeZone, "Arizona": arizonaTimeZone, "Arkansas": centralTimeZone,
+	"California": pacificTimeZone, "Colorado": mountainTimeZone, "Connecticut": easternTimeZone, "Delaware": easternTimeZone,
+	"Florida": easternTimeZone, "Georgia": easternTimeZone, "Hawaii": hawaiianTimeZone, "Idaho": mountainTimeZone,
+	"Illinois": centralTimeZone, "Indiana": easternTimeZone, "Iowa": centralTimeZone, "Kansas": centralTimeZone,
+	"Kentucky": easternTimeZone, "Louisiana": centralTimeZone, "Maine": easternTimeZone, "Maryland": easternTimeZone,
+	"Massachusetts": easternTimeZone, "Michigan": easternTimeZone, "Minnesota": centralTimeZone, "Mississippi": centralTimeZone,
+	"Missouri": centralTimeZone, "Montana": mountainTimeZone, "Nebraska": centralTimeZone, "Nevada": pacificTimeZone,
+	"New Hampshire": easternTimeZone, "New Jersey": easternTimeZone, "New Mexico": mountainTimeZone, "New York": easternTimeZone,
+	"North Carolina": easternTimeZone, "North Dakota": centralTimeZone, "Ohio": easternTimeZone, "Oklahoma": centralTimeZone,
+	"Oregon": pacificTimeZone, "Pennsylvania": easternTimeZone, "Rhode Island": easternTimeZone, "South Carolina": easternTimeZone,
+	"South Dakota": centralTimeZone, "Tennessee": centralTimeZone, "Texas": centralTimeZone, "Utah": mountainTimeZone,
+	"Vermont": easternTimeZone, "Virginia": easternTimeZone, "Washington": pacificTimeZone, "West Virginia": easternTimeZone,
+	"Wisconsin": centralTimeZone, "Wyoming": mountainTimeZone,
+}
+
+// generateTimeZone returns the time zone of the row's state when any address fields are
+// selected, so the location fields agree, or a random time zone otherwise.
+func generateTimeZone(rc rowContext) string {
+	if rc.hasAddress {
+		if zone, ok := stateTimeZones[rc.fields.Address.State]; ok {
+			return zone.name
+		}
+	}
+
+	return rc.faker.TimeZone()
+}
+
+// generateTimeZoneAbbr returns the abbreviation of the time zone of the row's state when
+// any address fields are selected, or a random time zone abbreviation otherwise.
+func generateTimeZoneAbbr(rc rowContext) string {
+	if rc.hasAddress {
+		if zone, ok := stateTimeZones[rc.fields.Address.State]; ok {
+			return zone.abbr
+		}
+	}
+
+	return rc.faker.TimeZoneAbv()
+}
+
+// firstNamesByGender are the first names used when the gender field is selected, as
+// gofakeit's first names aren't associated with a gender.
+var firstNamesByGender = map[string][]string{
+	"male": {
+		"James", "John", "Robert", "Michael", "William", "David", "Richard", "Joseph", "Thomas", "Charles",
+		"Christopher", "Daniel", "Matthew", "Anthony", "Mark", "Donald", "Steven", "Paul", "Andrew", "Joshua",
+		"Kenneth", "Kevin", "Brian", "George", "Timothy", "Ronald", "Edward", "Jason", "Jeffrey", "Ryan",
+		"Jacob", "Gary", "Nicholas", "Eric", "Jonathan", "Stephen", "Larry", "Justin", "Scott", "Brandon",
+	},
+	"female": {
+		"Mary", "Patricia", "Jennifer", "Linda", "Elizabeth", "Barbara", "Susan", "Jessica", "Sarah", "Karen",
+		"Lisa", "Nancy", "Betty", "Margaret", "Sandra", "Ashley", "Kimberly", "Emily", "Donna", "Michelle",
+		"Carol", "Amanda", "Dorothy", "Melissa", "Deborah", "Stephanie", "Rebecca", "Sharon", "Laura", "Cynthia",
+		"Kathleen", "Amy", "Angela", "Shirley", "Anna", "Brenda", "Pamela", "Emma", "Nicole", "Helen",
+	},
+}
+
+// baseFieldOptions selects the optional values generated along with the base fields.
+type baseFieldOptions struct {
+	// withGender generates a gender and picks a first name matching it, otherwise the
+	// first name is drawn from all of gofakeit's names.
+	withGender bool
+	// withCompany generates a company and derives the email domain from it, otherwise
+	// the email domain is random. The url field also reads the company's domain.
+	withCompany bool
+	// withJob generates a job, whose company is used as the company.
+	withJob bool
+	// withBirthdate generates an age and a birthdate matching it.
+	withBirthdate bool
+	// withPassword generates a password shared by the password and passwordhash fields.
+	withPassword bool
+	// withCreditCard generates a credit card shared by the credit card fields.
+	withCreditCard bool
+	// emailPrefix is prepended to the local part of the email, e.g. "test+".
+	emailPrefix string
+}
+
+// To maintain consistency between certain fields, base fields are generated together for
+// each row that includes any of the fields in baseDerivedFields.
+func generateBaseFields(faker *gofakeit.Faker, options baseFieldOptions) BaseFields {
+	var gender, firstName string
+	if options.withGender {
+		gender = faker.Gender()
+		firstName = faker.RandomString(firstNamesByGender[gender])
+	} else {
+		firstName = faker.FirstName()
+	}
+	lastName := faker.LastName()
+
+	var job *gofakeit.JobInfo
+	var company string
+	if options.withJob {
+		job = faker.Job()
+		company = job.Company
+	} else if options.withCompany {
+		company = faker.Company()
+	}
+
+	var emailDomain string
+	if options.withCompany {
+		emailDomain = companyDomain(company)
+	} else {
+		emailDomain = faker.DomainName()
+	}
+
+	name := fmt.Sprintf("%s %s", firstName, lastName)
+	// The username doubles as the local part of the email address.
+	username := fmt.Sprintf("%s.%s", strings.ToLower(firstName), strings.ToLower(lastName))
+	email := fmt.Sprintf("%s%s@%s", options.emailPrefix, username, emailDomain)
+
+	return BaseFields{
+		Name:      name,
+		FirstName: firstName,
+		LastName:  lastName,
+		Email:     email,
+		Username:  username,
+		Gender:    gender,
+		Company:   company,
+		Job:       job,
+		Address:   faker.Address(),
+		Country:   addressCountry,
+	}
+}
+
+// generatePairedFields draws the values of fields shared by a pair of selected fields,
+// such as the age and birthdate, which aren't derived from the rest of BaseFields. They
+// come from a faker of their own so selecting them doesn't change the other BaseFields.
+func generatePairedFields(faker *gofakeit.Faker, options baseFieldOptions, fields *BaseFields) {
+	if options.withBirthdate {
+		fields.Age = faker.Number(minAge, maxAge)
+		fields.Birthdate = birthdateForAge(faker, fields.Age, time.Now())
+	}
+	if options.withPassword {
+		fields.Password = newPassword(faker)
+	}
+	if options.withCreditCard {
+		fields.CreditCard = faker.CreditCard()
+	}
+}
+
+// birthdateForAge returns a random birthdate of someone who is age years old on now.
+func birthdateForAge(faker *gofakeit.Faker, age int, now time.Time) time.Time {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	latest := today.AddDate(-age, 0, 0)
+	earliest := today.AddDate(-age-1, 0, 1)
+
+	days := int(latest.Sub(earliest).Hours() / 24)
+	return earliest.AddDate(0, 0, faker.IntN(days+1))
+}
+
+// companyDomain derives an email domain from a company name, e.g. "Acme Corp, Inc."
+// becomes "acme-corp-inc.com".
+func companyDomain(company string) string {
+	words := strings.FieldsFunc(strings.ToLower(company), func(r rune) bool {
+		return !('a' <= r && r <= 'z' || '0' <= r && r <= '9')
+	})
+
+	return strings.Join(words, "-") + ".com"
+}
+
+// openOutput returns the destination for the generated data, creating the output
+// directory and file unless the data is being written to stdout. When opts.Gzip is set
+// the destination is wrapped so everything written to it is compressed. hasContent
+// reports whether the data is being appended to a file that isn't empty, in which case
+// the header row shouldn't be written again.
+func openOutput(opts Options, fileHandler FileHandler) (file io.WriteCloser, hasContent bool, err error) {
+	file, hasContent, err = openDestination(opts, fileHandler)
+	if err != nil || !opts.Gzip {
+		return file, hasContent, err
+	}
+
+	return gzipWriteCloser{Writer: gzip.NewWriter(file), file: file}, hasContent, nil
+}
+
+// defaultDirPerm is the permissions of the output directory when opts.DirPerm is empty.
+const defaultDirPerm os.FileMode = 0o755
+
+// parseDirPerm parses the octal permissions of the output directory, such as 0700.
+func parseDirPerm(perm string) (os.FileMode, error) {
+	if perm == "" {
+		return defaultDirPerm, nil
+	}
+
+	mode, err := strconv.ParseUint(perm, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("directory permissions must be an octal mode between 0000 and 0777: %q", perm)
+	}
+
+	return os.FileMode(mode), nil
+}
+
+func openDestination(opts Options, fileHandler FileHandler) (io.WriteCloser, bool, error) {
+	if opts.Filename == stdoutFilename {
+		return nopCloser{os.Stdout}, false, nil
+	}
+
+	perm, err := parseDirPerm(opts.DirPerm)
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = retry(opts.Retries, func() error { return fileHandler.MkDirAll(opts.OutputDir, perm) })
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	filePath := filepath.Join(opts.OutputDir, opts.Filename)
+	if opts.Append {
+		var file io.WriteCloser
+		var size int64
+		err := retry(opts.Retries, func() (err error) {
+			file, size, err = fileHandler.OpenAppend(filePath)
+			return err
+		})
+		return file, size > 0, err
+	}
+
+	if !opts.Overwrite {
+		exists, err := fileHandler.Exists(filePath)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to check for an existing file: %v", err)
+		}
+		if exists {
+			return nil, false, fmt.Errorf("%s already exists and overwriting is disabled", filePath)
+		}
+	}
+
+	var file io.WriteCloser
+	err = retry(opts.Retries, func() (err error) {
+		file, err = fileHandler.Create(filePath + tempSuffix)
+		return err
+	})
+	return file, false, err
+}
+
+// retryBackoff is how long to wait before retrying a failed file operation the first
+// time. The wait doubles with every retry after that.
+var retryBackoff = 100 * time.Millisecond
+
+// retry calls fn until it succeeds, retrying it up to retries times, which helps with the
+// transient failures of networked filesystems. The error of the last attempt is returned
+// if every attempt fails.
+func retry(retries int, fn func() error) error {
+	backoff := retryBackoff
+	err := fn()
+	for attempt := 0; attempt < retries && err != nil; attempt++ {
+		time.Sleep(backoff)
+		backoff *= 2
+		err = fn()
+	}
+
+	return err
+}
+
+// gzipWriteCloser compresses everything written to file. Closing it writes the gzip
+// trailer before closing the underlying file.
+type gzipWriteCloser struct {
+	*gzip.Writer
+	file io.WriteCloser
+}
+
+func (g gzipWriteCloser) Close() error {
+	if err := g.Writer.Close(); err != nil {
+		g.file.Close()
+		return err
+	}
+
+	return g.file.Close()
+}
+
+// closeOutput closes file once generation finishes, keeping the first error encountered
+// so a failure to write the end of the file isn't lost.
+func closeOutput(file io.Closer, err *error) {
+	if closeErr := file.Close(); closeErr != nil && *err == nil {
+		*err = fmt.Errorf("failed to close output: %v", closeErr)
+	}
+}
+
+// tempSuffix is appended to the name of the output file while it's being written.
+const tempSuffix = ".tmp"
+
+// finishOutput moves the output file into place once it's closed. The data is written to
+// a temporary file next to it, so readers never see a half written file, and is renamed
+// over the output file when the generation succeeds. When the generation fails the
+// temporary file is removed instead, so a failed run doesn't leave an incomplete file
+// behind, unless opts.KeepPartial is set or the generation was cancelled, as the rows
+// written before then are meant to be kept. A file being appended to is written in place.
+func finishOutput(opts Options, fileHandler FileHandler, err *error) {
+	if opts.Append || opts.Filename == stdoutFilename {
+		return
+	}
+
+	filePath := filepath.Join(opts.OutputDir, opts.Filename)
+	cancelled := errors.Is(*err, context.Canceled) || errors.Is(*err, context.DeadlineExceeded)
+	if *err != nil && !opts.KeepPartial && !cancelled {
+		if removeErr := fileHandler.Remove(filePath + tempSuffix); removeErr != nil {
+			*err = fmt.Errorf("%v (failed to remove partial output: %v)", *err, removeErr)
+		}
+		return
+	}
+
+	if renameErr := fileHandler.Rename(filePath+tempSuffix, filePath); renameErr != nil {
+		if *err == nil {
+			*err = fmt.Errorf("failed to move output into place: %v", renameErr)
+		} else {
+			*err = fmt.Errorf("%v (failed to move partial output into place: %v)", *err, renameErr)
+		}
+	}
+}
+
+// baseDerivedFields are the fields whose values are read from BaseFields.
+var baseDerivedFields = map[string]bool{
+	"name":          true,
+	"email":         true,
+	"firstName":     true,
+	"lastName":      true,
+	"city":          true,
+	"state":         true,
+	"zip":           true,
+	"address":       true,
+	"latitude":      true,
+	"longitude":     true,
+	"gender":        true,
+	"username":      true,
+	"jobTitle":      true,
+	"job":           true,
+	"jobDescriptor": true,
+	"jobLevel":      true,
+}
+
+// addressFields are the base derived fields read from BaseFields.Address.
+var addressFields = map[string]bool{
+	"city":      true,
+	"state":     true,
+	"zip":       true,
+	"address":   true,
+	"latitude":  true,
+	"longitude": true,
+}
+
+// rowGenerator generates rows for a single worker. BaseFields are drawn from their own
+// faker, and only when a base derived field is selected, so the values of the other
+// fields don't change depending on whether a base derived field is also selected. The
+// row's currency and paired fields have their own fakers for the same reason.
+type rowGenerator struct {
+	faker           *gofakeit.Faker
+	baseFaker       *gofakeit.Faker
+	currencyFaker   *gofakeit.Faker
+	pairedFaker     *gofakeit.Faker
+	opts            Options
+	columns         []column
+	needsBaseFields bool
+	needsAddress    bool
+	needsCurrency   bool
+	baseOptions     baseFieldOptions
+	ssnOffset       int64
+	// columnFakers holds the faker of each column with its own seed, and is nil for the
+	// other columns.
+	columnFakers []*columnFaker
+}
+
+// columnFaker is the faker of a column with its own seed.
+type columnFaker struct {
+	source    *rand.PCG
+	faker     *gofakeit.Faker
+	ssnOffset int64
+}
+
+// newRowGenerator returns the row generator used by the given worker. Each worker draws
+// from its own fakers seeded from the user's seed, so the output is reproducible for the
+// same seed and number of workers. A seed of 0 gives every worker random seeds.
+func newRowGenerator(opts Options, columns []column, worker int) *rowGenerator {
+	g := &rowGenerator{opts: opts, columns: columns}
+	selected := map[string]bool{}
+	for _, col := range columns {
+		for _, name := range col.fieldNames() {
+			selected[name] = true
+			g.needsBaseFields = g.needsBaseFields || baseDerivedFields[name]
+			g.needsAddress = g.needsAddress || addressFields[name]
+		}
+	}
+	g.baseOptions = baseFieldOptions{
+		withGender:     selected["gender"],
+		withCompany:    selected["company"] && (selected["email"] || selected["url"]),
+		withJob:        selected["job"] || selected["jobTitle"] || selected["jobDescriptor"] || selected["jobLevel"],
+		withBirthdate:  selected["age"] && selected["birthdate"],
+		withPassword:   selected["password"] && selected["passwordhash"],
+		withCreditCard: selected["creditcard"] && (selected["ccexpiry"] || selected["cvv"]),
+		emailPrefix:    opts.EmailPrefix,
+	}
+	g.needsBaseFields = g.needsBaseFields || g.baseOptions.withCompany || g.baseOptions.withBirthdate || g.baseOptions.withPassword || g.baseOptions.withCreditCard
+	g.needsCurrency = selected["currency"] || selected["currencyname"]
+	if selected["ssn"] {
+		g.ssnOffset = newSSNOffset(opts.Seed)
+	}
+
+	g.columnFakers = make([]*columnFaker, len(columns))
+	for i, col := range columns {
+		if col.seed == 0 {
+			continue
+		}
+
+		source := rand.NewPCG(0, 0)
+		g.columnFakers[i] = &columnFaker{source: source, faker: gofakeit.NewFaker(source, false), ssnOffset: newSSNOffset(col.seed)}
+	}
+
+	if opts.Seed == 0 {
+		g.faker = gofakeit.New(0)
+		g.baseFaker = gofakeit.New(0)
+		g.currencyFaker = gofakeit.New(0)
+		g.pairedFaker = gofakeit.New(0)
+		return g
+	}
+
+	// The fakers share a seed but use different PCG streams so they don't produce the
+	// same sequence of values.
+	seed := uint64(opts.Seed) + uint64(worker)
+	g.baseFaker = gofakeit.New(seed)
+	g.faker = gofakeit.NewFaker(rand.NewPCG(seed, ^seed), true)
+	g.currencyFaker = gofakeit.NewFaker(rand.NewPCG(^seed, seed), true)
+	g.pairedFaker = gofakeit.NewFaker(rand.NewPCG(^seed, ^seed), true)
+
+	return g
+}
+
+// generateRow generates the values for the row at the given index, in the same order as
+// the columns. Each value is replaced with an empty string at the column's null rate,
+// opts.NullRate unless the field is annotated with its own empty rate.
+func (g *rowGenerator) generateRow(index int64) []string {
+	return g.appendRow(nil, index)
+}
+
+// appendRow generates the row at the given index like generateRow, appending its values
+// to row so the caller can reuse the memory of a row it has finished with.
+func (g *rowGenerator) appendRow(row []string, index int64) []string {
+	rc := rowContext{faker: g.faker, opts: g.opts, hasAddress: g.needsAddress, row: index, ssnOffset: g.ssnOffset}
+	if g.needsBaseFields {
+		rc.fields = generateBaseFields(g.baseFaker, g.baseOptions)
+		generatePairedFields(g.pairedFaker, g.baseOptions, &rc.fields)
+	}
+	if g.needsCurrency {
+		rc.currency = g.currencyFaker.Currency()
+	}
+
+	for i, col := range g.columns {
+		if g.columnFakers[i] != nil {
+			row = append(row, g.generateSeededValue(col, g.columnFakers[i], rc))
+			continue
+		}
+
+		value := col.generate(rc)
+		if rate := col.nullRate(g.opts); rate > 0 && g.faker.Float64() < rate {
+			value = ""
+		}
+		row = append(row, value)
+	}
+
+	return row
+}
+
+// pairedBaseFields are the fields that only read BaseFields when a field they're paired
+// with is also selected, which is reported for a row generator by the function. A column
+// with its own seed draws its BaseFields from its own faker for these fields too, so its
+// values don't change with the user's seed.
+var pairedBaseFields = map[string]func(g *rowGenerator) bool{
+	"company":      func(g *rowGenerator) bool { return g.baseOptions.withCompany || g.baseOptions.withJob },
+	"url":          func(g *rowGenerator) bool { return g.baseOptions.withCompany || g.baseOptions.withJob },
+	"age":          func(g *rowGenerator) bool { return g.baseOptions.withBirthdate },
+	"birthdate":    func(g *rowGenerator) bool { return g.baseOptions.withBirthdate },
+	"password":     func(g *rowGenerator) bool { return g.baseOptions.withPassword },
+	"passwordhash": func(g *rowGenerator) bool { return g.baseOptions.withPassword },
+	"creditcard":   func(g *rowGenerator) bool { return g.baseOptions.withCreditCard },
+	"ccexpiry":     func(g *rowGenerator) bool { return g.baseOptions.withCreditCard },
+	"cvv":          func(g *rowGenerator) bool { return g.baseOptions.withCreditCard },
+	"department":   func(g *rowGenerator) bool { return g.baseOptions.withJob },
+	"timezone":     func(g *rowGenerator) bool { return g.needsAddress },
+	"timezoneabbr": func(g *rowGenerator) bool { return g.needsAddress },
+}
+
+// generateSeededValue generates the value of a column with its own seed. Its faker is
+// reseeded from the column's seed and the row index for every row, so the value doesn't
+// depend on the user's seed, the other fields or the number of workers. Any BaseFields or
+// currency the value is read from are drawn from the column's faker too.
+func (g *rowGenerator) generateSeededValue(col column, cf *columnFaker, rc rowContext) string {
+	cf.source.Seed(uint64(col.seed), uint64(rc.row))
+	rc.faker = cf.faker
+	rc.ssnOffset = cf.ssnOffset
+
+	var usesBaseFields, usesCurrency bool
+	for _, name := range col.fieldNames() {
+		pairedWith, paired := pairedBaseFields[name]
+		usesBaseFields = usesBaseFields || baseDerivedFields[name] || (paired && pairedWith(g))
+		usesCurrency = usesCurrency || name == "currency" || name == "currencyname"
+	}
+	if usesBaseFields {
+		rc.fields = generateBaseFields(cf.faker, g.baseOptions)
+		generatePairedFields(cf.faker, g.baseOptions, &rc.fields)
+	}
+	if usesCurrency {
+		rc.currency = cf.faker.Currency()
+	}
+
+	value := col.generate(rc)
+	if rate := col.nullRate(g.opts); rate > 0 && cf.faker.Float64() < rate {
+		value = ""
+	}
+
+	return value
+}
+
+// generateRows generates opts.Rows rows across opts.Workers goroutines and returns them in
+// order on the returned channel. Row i is always generated by worker i % opts.Workers and
+// the rows are read back from the workers in the same round robin order, so rows are
+// written in the order they were generated without needing to be sorted. Closing done
+// stops the workers early. With opts.Static a single row is generated and repeated
+// instead.
+//
+// Rows the caller has finished with can be handed back with recycle.add, and the workers
+// generate new rows into them instead of allocating a slice for every row.
+func generateRows(opts Options, columns []column, recycle rowRecycler, done <-chan struct{}) <-chan []string {
+	rows := opts.rowCount()
+	if opts.Static {
+		return repeatRow(newRowGenerator(opts, columns, 0).generateRow(0), rows, done)
+	}
+
+	workers := int64(max(opts.Workers, 1))
+
+	workerRows := make([]chan []string, workers)
+	for w := range workerRows {
+		workerRows[w] = make(chan []string, rowBufferSize)
+		go func(start int64, generator *rowGenerator, out chan<- []string) {
+			defer close(out)
+			for i := start; i < rows; i += workers {
+				select {
+				case out <- generator.appendRow(recycle.get(len(columns)), i):
+				case <-done:
+					return
+				}
+			}
+		}(int64(w), newRowGenerator(opts, columns, w), workerRows[w])
+	}
+
+	out := make(chan []string, rowBufferSize)
+	go func() {
+		defer close(out)
+		for i := int64(0); i < rows; i++ {
+			row := <-workerRows[i%workers]
+			select {
+			case out <- row:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// repeatRow returns a channel that receives row the given number of times, for static
+// output where every row is the same. The row is never recycled, as the writer only ever
+// hands back the same row.
+func repeatRow(row []string, rows int64, done <-chan struct{}) <-chan []string {
+	out := make(chan []string, rowBufferSize)
+	go func() {
+		defer close(out)
+		for i := int64(0); i < rows; i++ {
+			select {
+			case out <- row:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// rowCount returns the number of rows to generate. A run limited by MaxBytes generates
+// rows until the writer stops reading them.
+func (o Options) rowCount() int64 {
+	if o.MaxBytes > 0 {
+		return math.MaxInt64
+	}
+
+	return o.Rows
+}
+
+// byteLimit counts the bytes written through it, so a run limited by Options.MaxBytes
+// can stop once the output reaches the limit. A max of 0 means there's no limit.
+type byteLimit struct {
+	w       io.Writer
+	max     int64
+	written int64
+}
+
+func (b *byteLimit) Write(p []byte) (int, error) {
+	n, err := b.w.Write(p)
+	b.written += int64(n)
+	return n, err
+}
+
+// reached reports whether at least max bytes have been written.
+func (b *byteLimit) reached() bool {
+	return b.max > 0 && b.written >= b.max
+}
+
+// rowRecycler holds rows that have been written so their memory can be reused for the
+// rows generated after them. Only as many rows as fit in its buffer are kept, the rest are
+// left to the garbage collector.
+type rowRecycler chan []string
+
+// newRowRecycler returns a rowRecycler for the rows of workers row generators.
+func newRowRecycler(workers int) rowRecycler {
+	// Enough rows to keep every worker's buffer and the output buffer full.
+	return make(rowRecycler, (max(workers, 1)+1)*rowBufferSize)
+}
+
+// add hands back a row that is no longer used.
+func (r rowRecycler) add(row []string) {
+	select {
+	case r <- row[:0]:
+	default:
+	}
+}
+
+// get returns an empty row to generate a row of the given number of columns into.
+func (r rowRecycler) get(columns int) []string {
+	select {
+	case row := <-r:
+		return row
+	default:
+		return make([]string, 0, columns)
+	}
+}
+
+// maxUniqueAttempts is the number of times a row is regenerated looking for an unused
+// value of the unique field before giving up.
+const maxUniqueAttempts = 100
+
+// uniqueEnforcer makes sure the field named by opts.Unique doesn't repeat across rows.
+// Rows are checked in the order they're written and a row with a duplicate value is
+// replaced with a row from its own generator, so the output stays reproducible for the
+// same seed and number of workers. Empty values, such as those injected by -nullrate,
+// aren't considered duplicates.
+type uniqueEnforcer struct {
+	index     int
+	name      string
+	seen      map[string]bool
+	generator *rowGenerator
+	// rows is the number of rows accepted so far, and so the index of the next row.
+	rows int64
+}
+
+// newUniqueEnforcer returns the enforcer for opts.Unique, which has to name one of the
+// columns. When opts.Unique is empty every row is accepted as is.
+func newUniqueEnforcer(opts Options, columns []column) (*uniqueEnforcer, error) {
+	u := &uniqueEnforcer{index: -1, name: opts.Unique}
+	if opts.Unique == "" {
+		return u, nil
+	}
+
+	for i, col := range columns {
+		if col.name == opts.Unique {
+			u.index = i
+		}
+	}
+	if u.index == -1 {
+		return nil, fmt.Errorf("unique field %q is not one of the selected fields", opts.Unique)
+	}
+
+	// A column with its own seed always generates the same value for a row, so a repeated
+	// value can't be replaced.
+	if columns[u.index].seed != 0 {
+		return nil, fmt.Errorf("unique field %q can't have its own seed", opts.Unique)
+	}
+
+	u.seen = map[string]bool{}
+	u.generator = newRowGenerator(opts, columns, max(opts.Workers, 1))
+
+	return u, nil
+}
+
+// enforce returns row, or a replacement for it when its unique value has already been
+// used.
+func (u *uniqueEnforcer) enforce(row []string) ([]string, error) {
+	if u.index == -1 {
+		return row, nil
+	}
+
+	for attempt := 0; attempt < maxUniqueAttempts; attempt++ {
+		value := row[u.index]
+		if value == "" || !u.seen[value] {
+			u.seen[value] = true
+			u.rows++
+			return row, nil
+		}
+
+		row = u.generator.generateRow(u.rows)
+	}
+
+	return nil, fmt.Errorf("unable to generate a unique %s after %d attempts, %d values have been used", u.name, maxUniqueAttempts, len(u.seen))
+}
+
+// progressInterval is the number of rows written between progress updates.
+const progressInterval = 10000
+
+// progressReporter prints how many rows have been written to opts.Log during long runs.
+// The total is 0 when it isn't known up front because the run is limited by MaxBytes.
+type progressReporter struct {
+	out       io.Writer
+	quiet     bool
+	total     int64
+	written   int64
+	startTime time.Time
+	result    *int64
+}
+
+func newProgressReporter(opts Options) *progressReporter {
+	total := opts.Rows
+	if opts.MaxBytes > 0 {
+		total = 0
+	}
+
+	return &progressReporter{out: opts.logOutput(), quiet: opts.Quiet, total: total, startTime: time.Now(), result: opts.rowsWritten}
+}
+
+// rowWritten records a written row, printing an update every progressInterval rows.
+func (p *progressReporter) rowWritten() {
+	p.written++
+	if p.result != nil {
+		*p.result = p.written
+	}
+	if p.quiet || p.written%progressInterval != 0 {
+		return
+	}
+
+	elapsed := time.Since(p.startTime)
+	if p.total == 0 {
+		fmt.Fprintf(p.out, "Wrote %d rows (%.1f seconds)\n", p.written, elapsed.Seconds())
+		return
+	}
+
+	percent := float64(p.written) / float64(p.total) * 100
+	fmt.Fprintf(p.out, "Wrote %d of %d rows (%.1f%%, %.1f seconds)\n", p.written, p.total, percent, elapsed.Seconds())
+}
+
+// rowBufferSize is the number of generated rows each worker can get ahead of the writer.
+const rowBufferSize = 256
+
+// writeBufferSize is the size of the buffer generated rows are collected in before being
+// written to the output, which avoids a write to the file for every row.
+const writeBufferSize = 64 * 1024
+
+// DataGenerator generates the data in a single output format. generateCsvDataContext
+// writes it to the output file, or stdout, and writeData writes it to w. hasContent
+// reports whether w already holds data, in which case no header should be written. Both
+// stop early when ctx is cancelled, keeping the rows written so far.
+type DataGenerator interface {
+	generateCsvDataContext(ctx context.Context, opts Options, fileHandler FileHandler, csvWriter FileWriter) error
+	writeData(ctx context.Context, w io.Writer, hasContent bool, opts Options, csvWriter FileWriter) error
+}
+
+// cancelCheckInterval is the number of rows written between checks of whether generation
+// has been cancelled.
+const cancelCheckInterval = 1000
+
+// checkCancelled returns an error when ctx has been cancelled, only checking it every
+// cancelCheckInterval rows.
+func checkCancelled(ctx context.Context, written int64) error {
+	if written%cancelCheckInterval != 0 || ctx.Err() == nil {
+		return nil
+	}
+
+	return fmt.Errorf("generation cancelled after %d rows: %w", written, ctx.Err())
+}
+
+// parseOutputColumns parses the selected fields, followed by any foreign keys of a table
+// generated by GenerateTables, and the unique field constraint on them.
+func parseOutputColumns(opts Options) ([]column, *uniqueEnforcer, error) {
+	columns, err := parseColumns(opts.Fields)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if opts.referenced {
+		for i := range columns {
+			columns[i].notNull = columns[i].notNull || columns[i].name == "id"
+		}
+	}
+	columns = append(columns, opts.foreignKeys...)
+
+	unique, err := newUniqueEnforcer(opts, columns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return columns, unique, nil
+}
+
+// dataGenerators maps each supported output format to the generator that writes it.
+var dataGenerators = map[string]DataGenerator{
+	"csv":   CSVDataGenerator{},
+	"json":  JSONDataGenerator{},
+	"fixed": FixedWidthDataGenerator{},
+}
+
+type CSVDataGenerator struct{}
+
+// newRecordWriter returns the writer used to write CSV records to w, quoting every field
+// when opts.AlwaysQuote is set and ending lines with \r\n when opts.CRLF is set.
+func newRecordWriter(w io.Writer, opts Options) RecordWriter {
+	if opts.AlwaysQuote {
+		return newAlwaysQuoteWriter(w, opts.delimiterRune(), opts.CRLF)
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Comma = opts.delimiterRune()
+	writer.UseCRLF = opts.CRLF
+	return writer
+}
+
+func (d CSVDataGenerator) generateCsvData(opts Options, fileHandler FileHandler, csvWriter FileWriter) error {
+	return d.generateCsvDataContext(context.Background(), opts, fileHandler, csvWriter)
+}
+
+func (d CSVDataGenerator) generateCsvDataContext(ctx context.Context, opts Options, fileHandler FileHandler, csvWriter FileWriter) (err error) {
+	// The columns are checked before the output is opened so an invalid selection doesn't
+	// leave an empty file behind.
+	if _, _, err := parseOutputColumns(opts); err != nil {
+		return err
+	}
+
+	file, hasContent, err := openOutput(opts, fileHandler)
+	if err != nil {
+		return err
+	}
+	defer finishOutput(opts, fileHandler, &err)
+	defer closeOutput(file, &err)
+
+	return d.writeData(ctx, file, hasContent, opts, csvWriter)
+}
+
+func (d CSVDataGenerator) writeData(ctx context.Context, w io.Writer, hasContent bool, opts Options, csvWriter FileWriter) (err error) {
+	columns, unique, err := parseOutputColumns(opts)
+	if err != nil {
+		return err
+	}
+
+	buffered := bufio.NewWriterSize(w, writeBufferSize)
+	limit := &byteLimit{w: buffered, max: opts.MaxBytes}
+	writer := newRecordWriter(limit, opts)
+
+	fieldSlice := columnNames(columns)
+
+	if !hasContent {
+		// The byte order mark starts the file, so it's left out when appending.
+		if opts.BOM {
+			if _, err := buffered.WriteString(utf8BOM); err != nil {
+				return fmt.Errorf("failed to write byte order mark: %v", err)
+			}
+		}
+
+		// The comment is written around the record writer, which would quote it.
+		if opts.Comment {
+			if _, err := buffered.WriteString(commentLine(opts)); err != nil {
+				return fmt.Errorf("failed to write comment line: %v", err)
+			}
+		}
+
+		if err := csvWriter.Write(fieldSlice, writer); err != nil {
+			return fmt.Errorf("failed to write header row: %v", err)
+		}
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	// A cancelled generation still flushes the rows written so far, so the output ends
+	// with a complete row.
+	var cancelErr error
+	progress := newProgressReporter(opts)
+	recycle := newRowRecycler(opts.Workers)
+	for row := range generateRows(opts, columns, recycle, done) {
+		if cancelErr = checkCancelled(ctx, progress.written); cancelErr != nil {
+			break
+		}
+
+		if row, err = unique.enforce(row); err != nil {
+			return err
+		}
+
+		if err := csvWriter.Write(row, writer); err != nil {
+			return fmt.Errorf("failed to write row: %v", err)
+		}
+		progress.rowWritten()
+		recycle.add(row)
+
+		// The record writer holds rows back, so they're only counted once flushed.
+		if opts.MaxBytes > 0 {
+			writer.Flush()
+			if limit.reached() {
+				break
+			}
+		}
+	}
+
+	// The record writer has to be flushed into the buffered writer before the buffered
+	// writer is flushed to the file.
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to flush rows: %v", err)
+	}
+
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("failed to flush rows: %v", err)
+	}
+
+	return cancelErr
+}
+
+// commentPrefix starts the comment line written before the header with opts.Comment, as
+// tools that accept comments in CSV files expect.
+const commentPrefix = "#"
+
+// commentLine returns the line written before the header with opts.Comment, recording the
+// seed the data was generated from.
+func commentLine(opts Options) string {
+	line := fmt.Sprintf("%s generated by go-test-csv-generator seed=%d", commentPrefix, opts.Seed)
+	if opts.CRLF {
+		return line + "\r\n"
+	}
+
+	return line + "\n"
+}
+
+// utf8BOM is the UTF-8 byte order mark, which tells tools such as Excel that a file is
+// UTF-8 encoded.
+const utf8BOM = "\xEF\xBB\xBF"
+
+// JSONDataGenerator writes newline delimited JSON, with each row written as an object
+// keyed by field name. The csvWriter is unused.
+type JSONDataGenerator struct{}
+
+func (d JSONDataGenerator) generateCsvData(opts Options, fileHandler FileHandler, csvWriter FileWriter) error {
+	return d.generateCsvDataContext(context.Background(), opts, fileHandler, csvWriter)
+}
+
+func (d JSONDataGenerator) generateCsvDataContext(ctx context.Context, opts Options, fileHandler FileHandler, csvWriter FileWriter) (err error) {
+	if _, _, err := parseOutputColumns(opts); err != nil {
+		return err
+	}
+
+	file, hasContent, err := openOutput(opts, fileHandler)
+	if err != nil {
+		return err
+	}
+	defer finishOutput(opts, fileHandler, &err)
+	defer closeOutput(file, &err)
+
+	return d.writeData(ctx, file, hasContent, opts, csvWriter)
+}
+
+// writeData writes a JSON object for each row to w. JSON has no header, so hasContent has
+// no effect.
+func (d JSONDataGenerator) writeData(ctx context.Context, w io.Writer, hasContent bool, opts Options, csvWriter FileWriter) (err error) {
+	columns, unique, err := parseOutputColumns(opts)
+	if err != nil {
+		return err
+	}
+
+	buffered := bufio.NewWriterSize(w, writeBufferSize)
+	limit := &byteLimit{w: buffered, max: opts.MaxBytes}
+	fieldSlice := columnNames(columns)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	var cancelErr error
+	progress := newProgressReporter(opts)
+	recycle := newRowRecycler(opts.Workers)
+	for row := range generateRows(opts, columns, recycle, done) {
+		if cancelErr = checkCancelled(ctx, progress.written); cancelErr != nil {
+			break
+		}
+
+		if row, err = unique.enforce(row); err != nil {
+			return err
+		}
+
+		if _, err := limit.Write(marshalJSONRow(fieldSlice, row)); err != nil {
+			return fmt.Errorf("failed to write row: %v", err)
+		}
+		progress.rowWritten()
+		recycle.add(row)
+
+		if limit.reached() {
+			break
+		}
+	}
+
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("failed to flush rows: %v", err)
+	}
+
+	return cancelErr
+}
+
+// FixedWidthDataGenerator writes each row as a line of fixed width columns, with the width
+// of each column given by opts.Widths. There's no header, and the csvWriter is unused.
+type FixedWidthDataGenerator struct{}
+
+func (d FixedWidthDataGenerator) generateCsvDataContext(ctx context.Context, opts Options, fileHandler FileHandler, csvWriter FileWriter) (err error) {
+	if _, _, err := parseOutputColumns(opts); err != nil {
+		return err
+	}
+
+	file, hasContent, err := openOutput(opts, fileHandler)
+	if err != nil {
+		return err
+	}
+	defer finishOutput(opts, fileHandler, &err)
+	defer closeOutput(file, &err)
+
+	return d.writeData(ctx, file, hasContent, opts, csvWriter)
+}
+
+// writeData writes a fixed width line for each row to w. There's no header, so hasContent
+// has no effect.
+func (d FixedWidthDataGenerator) writeData(ctx context.Context, w io.Writer, hasContent bool, opts Options, csvWriter FileWriter) (err error) {
+	columns, unique, err := parseOutputColumns(opts)
+	if err != nil {
+		return err
+	}
+
+	widths, err := parseWidths(opts.Widths)
+	if err != nil {
+		return err
+	}
+	if len(widths) != len(columns) {
+		return fmt.Errorf("%d column widths given for %d columns", len(widths), len(columns))
+	}
+
+	buffered := bufio.NewWriterSize(w, writeBufferSize)
+	limit := &byteLimit{w: buffered, max: opts.MaxBytes}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	var cancelErr error
+	var line []byte
+	progress := newProgressReporter(opts)
+	recycle := newRowRecycler(opts.Workers)
+	for row := range generateRows(opts, columns, recycle, done) {
+		if cancelErr = checkCancelled(ctx, progress.written); cancelErr != nil {
+			break
+		}
+
+		if row, err = unique.enforce(row); err != nil {
+			return err
+		}
+
+		line = appendFixedWidthRow(line[:0], row, widths, opts.CRLF)
+		if _, err := limit.Write(line); err != nil {
+			return fmt.Errorf("failed to write row: %v", err)
+		}
+		progress.rowWritten()
+		recycle.add(row)
+
+		if limit.reached() {
+			break
+		}
+	}
+
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("failed to flush rows: %v", err)
+	}
+
+	return cancelErr
+}
+
+// parseWidths parses a comma separated list of column widths, e.g. "20,3,30".
+func parseWidths(s string) ([]int, error) {
+	if s == "" {
+		return nil, fmt.Errorf("fixed width output requires a width for every column")
+	}
+
+	var widths []int
+	for _, part := range strings.Split(s, ",") {
+		width, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || width <= 0 {
+			return nil, fmt.Errorf("column widths must be positive integers: %q", s)
+		}
+		widths = append(widths, width)
+	}
+
+	return widths, nil
+}
+
+// appendFixedWidthRow appends row to line with each value left aligned in its column,
+// padded with spaces or truncated to the column's width. Widths are counted in
+// characters rather than bytes, so a multi-byte character is never cut in half.
+func appendFixedWidthRow(line []byte, row []string, widths []int, crlf bool) []byte {
+	for i, value := range row {
+		width := widths[i]
+		for _, r := range value {
+			if width == 0 {
+				break
+			}
+			line = utf8.AppendRune(line, r)
+			width--
+		}
+		for ; width > 0; width-- {
+			line = append(line, ' ')
+		}
+	}
+
+	if crlf {
+		line = append(line, '\r')
+	}
+	return append(line, '\n')
+}
+
+// marshalJSONRow encodes a row as a single line JSON object. The object is built by hand
+// rather than from a map so the keys keep the order the fields were selected in.
+func marshalJSONRow(fieldSlice []string, row []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range fieldSlice {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		// Marshalling a string can't fail.
+		key, _ := json.Marshal(field)
+		value, _ := json.Marshal(row[i])
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteString("}\n")
+
+	return buf.Bytes()
+}
+
+// randomSeed returns a random, non-zero seed.
+func randomSeed() int {
+	return rand.IntN(math.MaxInt) + 1
+}
+
+func generate(ctx context.Context, fileHandler FileHandler, writer FileWriter, generator DataGenerator, opts Options) error {
+	startTime := time.Now()
+
+	// A seed of 0 means the user didn't ask for reproducible output, a random seed is
+	// picked and printed so the run can still be reproduced later.
+	if opts.Seed == 0 {
+		opts.Seed = randomSeed()
+	}
+
+	out := opts.logOutput()
+
+	if opts.MaxBytes > 0 {
+		fmt.Fprintf(out, "Max bytes: %d\n", opts.MaxBytes)
+	} else {
+		fmt.Fprintf(out, "Rows: %d\n", opts.Rows)
+	}
+	fmt.Fprintf(out, "Fields: %s\n", opts.Fields)
+	fmt.Fprintf(out, "Filename: %s\n", opts.Filename)
+	fmt.Fprintf(out, "Seed: %d\n", opts.Seed)
+	fmt.Fprintf(out, "Generating CSV file...\n")
+
+	var rows int64
+	opts.rowsWritten = &rows
+
+	counter := &countingFileHandler{FileHandler: fileHandler}
+	if err := generator.generateCsvDataContext(ctx, opts, counter, writer); err != nil {
+		return fmt.Errorf("Failed to generate CSV data: %v", err)
+	}
+
+	var verified int64
+	if opts.Verify {
+		var err error
+		if verified, err = verifyOutput(opts, fileHandler); err != nil {
+			return fmt.Errorf("Failed to verify CSV data: %v", err)
+		}
+	}
+
+	elapsed := time.Since(startTime)
+
+	if opts.Filename == stdoutFilename {
+		fmt.Fprintf(out, "CSV data successfully written to stdout.\n")
+	} else {
+		fmt.Fprintf(out, "CSV file successfully generated at %s/%s.\n", opts.OutputDir, opts.Filename)
+	}
+	fmt.Fprintf(out, "(Elapsed time: %f seconds)\n", elapsed.Seconds())
+	if opts.Verify {
+		fmt.Fprintf(out, "Verified %d records.\n", verified)
+	}
+
+	if opts.Report != "" {
+		report := newReport(opts, rows, counter.written, elapsed)
+		if err := writeJSONFile(report, opts.Report, fileHandler); err != nil {
+			return fmt.Errorf("Failed to write report: %v", err)
+		}
+	}
+
+	if opts.manifest != nil {
+		opts.manifest.Files = append(opts.manifest.Files, ManifestFile{Path: outputPath(opts), Rows: rows})
+	}
+
+	return nil
+}
+
+// verifyOutput reads back the generated file and checks it parses as CSV with a field
+// for every column on each line, returning the number of records read including the
+// header. A field with an unquoted newline or delimiter shows up as a record with the
+// wrong number of fields.
+func verifyOutput(opts Options, fileHandler FileHandler) (int64, error) {
+	columns, _, err := parseOutputColumns(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := fileHandler.Open(filepath.Join(opts.OutputDir, opts.Filename))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open output: %v", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if opts.Gzip {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decompress output: %v", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	// The comment line isn't a record, so it's skipped along with any byte order mark
+	// before it, rather than read with the reader's Comment, which would also skip any
+	// rows starting with the prefix.
+	if opts.Comment {
+		buffered := bufio.NewReader(r)
+		if prefix, err := buffered.Peek(len(utf8BOM)); err == nil && string(prefix) == utf8BOM {
+			buffered.Discard(len(utf8BOM))
+		}
+		if prefix, err := buffered.Peek(len(commentPrefix)); err == nil && string(prefix) == commentPrefix {
+			if _, err := buffered.ReadString('\n'); err != nil {
+				return 0, fmt.Errorf("failed to read comment line: %v", err)
+			}
+		}
+		r = buffered
+	}
+
+	reader := csv.NewReader(r)
+	reader.Comma = opts.delimiterRune()
+	reader.FieldsPerRecord = len(columns)
+	reader.ReuseRecord = true
+
+	var records int64
+	for {
+		if _, err := reader.Read(); err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return records, err
+		}
+		records++
+	}
+}
+
+// Report is the machine readable summary of a run written by -report.
+type Report struct {
+	Rows   int64    `json:"rows"`
+	Fields []string `json:"fields"`
+	Seed   int      `json:"seed"`
+	Output string   `json:"output"`
+	// Bytes is the number of bytes written to the output file, after compression. It's
+	// 0 when the data is written to stdout.
+	Bytes          int64   `json:"bytes"`
+	ElapsedSeconds float64 `json:"elapsedSeconds"`
+}
+
+func newReport(opts Options, rows int64, written int64, elapsed time.Duration) Report {
+	return Report{
+		Rows:           rows,
+		Fields:         splitFields(opts.Fields),
+		Seed:           opts.Seed,
+		Output:         outputPath(opts),
+		Bytes:          written,
+		ElapsedSeconds: elapsed.Seconds(),
+	}
+}
+
+// outputPath returns the path of the file opts writes to, or "-" for stdout.
+func outputPath(opts Options) string {
+	if opts.Filename == stdoutFilename {
+		return opts.Filename
+	}
+
+	return filepath.Join(opts.OutputDir, opts.Filename)
+}
+
+// Manifest lists every file written by a run, written by -manifest once the run is done.
+// A run of GenerateTables lists a file for each table, in the order they're defined.
+type Manifest struct {
+	Files []ManifestFile `json:"files"`
+}
+
+// ManifestFile is a single file of a Manifest, with the number of rows written to it.
+type ManifestFile struct {
+	Path string `json:"path"`
+	Rows int64  `json:"rows"`
+}
+
+// writeManifest writes the files collected in opts.manifest to opts.Manifest, when set.
+func writeManifest(opts Options, fileHandler FileHandler) error {
+	if opts.manifest == nil {
+		return nil
+	}
+
+	if err := writeJSONFile(opts.manifest, opts.Manifest, fileHandler); err != nil {
+		return fmt.Errorf("Failed to write manifest: %v", err)
+	}
+
+	return nil
+}
+
+// writeJSONFile writes value as JSON to path, or to stderr when path is "-" as stdout may
+// be in use for the generated data.
+func writeJSONFile(value any, path string, fileHandler FileHandler) (err error) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if path == stdoutFilename {
+		_, err = os.Stderr.Write(data)
+		return err
+	}
+
+	file, err := fileHandler.Create(path)
+	if err != nil {
+		return err
+	}
+	defer closeOutput(file, &err)
+
+	_, err = file.Write(data)
+	return err
+}
+
+// countingFileHandler counts the bytes written to the files it creates or opens.
+type countingFileHandler struct {
+	FileHandler
+	written int64
+}
+
+func (c *countingFileHandler) Create(name string) (io.WriteCloser, error) {
+	file, err := c.FileHandler.Create(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &countingWriteCloser{WriteCloser: file, written: &c.written}, nil
+}
+
+func (c *countingFileHandler) OpenAppend(name string) (io.WriteCloser, int64, error) {
+	file, size, err := c.FileHandler.OpenAppend(name)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &countingWriteCloser{WriteCloser: file, written: &c.written}, size, nil
+}
+
+type countingWriteCloser struct {
+	io.WriteCloser
+	written *int64
+}
+
+func (c *countingWriteCloser) Write(p []byte) (int, error) {
+	n, err := c.WriteCloser.Write(p)
+	*c.written += int64(n)
+	return n, err
+}
+
+// ReadFieldsFile reads a list of fields separated by commas, newlines or both from path,
+// and returns it in the comma separated form used by Options.Fields. Blank lines and the
+// space around each field are ignored.
+func ReadFieldsFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read fields file: %v", err)
+	}
+
+	fieldSlice := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		for _, field := range splitFields(line) {
+			if field = strings.TrimSpace(field); field != "" {
+				fieldSlice = append(fieldSlice, field)
+			}
+		}
+	}
+
+	return strings.Join(fieldSlice, ","), nil
+}
+
+// countOnly generates the rows into io.Discard and writes how fast they were generated to
+// out.
+func countOnly(opts Options, out io.Writer) error {
+	if opts.Seed == 0 {
+		opts.Seed = randomSeed()
+	}
+
+	var rows int64
+	opts.rowsWritten = &rows
+
+	startTime := time.Now()
+	if err := dataGenerators[opts.Format].writeData(context.Background(), io.Discard, false, opts, CSVFileWriter{}); err != nil {
+		return fmt.Errorf("Failed to generate CSV data: %v", err)
+	}
+	elapsed := time.Since(startTime)
+
+	_, err := fmt.Fprintf(out, "Generated %d rows in %.3f seconds (%.0f rows/sec)\n", rows, elapsed.Seconds(), float64(rows)/elapsed.Seconds())
+	return err
+}
+
+// estimateSampleRows is the number of rows generated to estimate the size of the output.
+const estimateSampleRows = 100
+
+// estimate writes the estimated size of the output to out.
+func estimate(opts Options, out io.Writer) error {
+	size, rowSize, err := estimateSize(opts)
+	if err != nil {
+		return err
+	}
+
+	compression := ""
+	if opts.Gzip {
+		compression = ", before compression"
+	}
+
+	_, err = fmt.Fprintf(out, "Estimated output size: %s (%d rows of about %.1f bytes%s)\n", formatByteSize(size), opts.Rows, rowSize, compression)
+	return err
+}
+
+// estimateSize generates a sample of up to estimateSampleRows rows and returns the
+// estimated size in bytes of the uncompressed output of opts.Rows rows, along with the
+// average size of a row. The header is only counted once.
+func estimateSize(opts Options) (int64, float64, error) {
+	sample := opts
+	sample.Rows = min(opts.Rows, estimateSampleRows)
+	sample.MaxBytes = 0
+	sample.Quiet = true
+	sample.Log = nil
+	if sample.Seed == 0 {
+		sample.Seed = randomSeed()
+	}
+
+	var data bytes.Buffer
+	if err := dataGenerators[opts.Format].writeData(context.Background(), &data, false, sample, CSVFileWriter{}); err != nil {
+		return 0, 0, err
+	}
+
+	// JSON has no header, a CSV header, byte order mark and comment line are measured by
+	// writing them on their own.
+	var header bytes.Buffer
+	if opts.Format == "csv" {
+		columns, _, err := parseOutputColumns(opts)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if opts.BOM {
+			header.WriteString(utf8BOM)
+		}
+		if opts.Comment {
+			header.WriteString(commentLine(sample))
+		}
+
+		writer := newRecordWriter(&header, opts)
+		writer.Write(columnNames(columns))
+		writer.Flush()
+	}
+
+	rowSize := float64(data.Len()-header.Len()) / float64(sample.Rows)
+	return int64(header.Len()) + int64(rowSize*float64(opts.Rows)), rowSize, nil
+}
+
+// formatByteSize formats a number of bytes with a decimal unit, e.g. "1.5 MB".
+func formatByteSize(size int64) string {
+	const unit = 1000
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value, suffix := float64(size), ""
+	for _, suffix = range []string{"kB", "MB", "GB", "TB", "PB"} {
+		value /= unit
+		if value < unit {
+			break
+		}
+	}
+
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
+
+// dryRun previews the output by writing the header row and a single sample row to out,
+// without creating the output directory or file.
+func dryRun(opts Options, out io.Writer) error {
+	columns, err := parseColumns(opts.Fields)
+	if err != nil {
+		return err
+	}
+
+	if _, err := newUniqueEnforcer(opts, columns); err != nil {
+		return err
+	}
+
+	fieldSlice := columnNames(columns)
+	row := newRowGenerator(opts, columns, 0).generateRow(0)
+
+	if opts.Format == "json" {
+		_, err := out.Write(marshalJSONRow(fieldSlice, row))
+		return err
+	}
+
+	if opts.Format == "fixed" {
+		widths, err := parseWidths(opts.Widths)
+		if err != nil {
+			return err
+		}
+
+		_, err = out.Write(appendFixedWidthRow(nil, row, widths, opts.CRLF))
+		return err
+	}
+
+	writer := newRecordWriter(out, opts)
+	writer.Write(fieldSlice)
+	writer.Write(row)
+	writer.Flush()
+
+	return writer.Error()
+}
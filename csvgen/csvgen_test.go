@@ -0,0 +1,2736 @@
+package csvgen
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/brianvoe/gofakeit/v7"
+)
+
+type MockDataGenerator struct {
+	ShouldFail bool
+}
+
+func (d MockDataGenerator) generateCsvDataContext(ctx context.Context, opts Options, fileHandler FileHandler, csvWriter FileWriter) error {
+	if d.ShouldFail {
+		return fmt.Errorf("generateCsvData failed")
+	}
+
+	return nil
+}
+
+func (d MockDataGenerator) writeData(ctx context.Context, w io.Writer, hasContent bool, opts Options, csvWriter FileWriter) error {
+	return d.generateCsvDataContext(ctx, opts, nil, csvWriter)
+}
+
+type MockFileHandler struct {
+	ShouldFailMkDirAll bool
+	ShouldFailCreate   bool
+	ShouldFailWrite    bool
+	ShouldFailClose    bool
+	ShouldFailAppend   bool
+	ShouldFailExists   bool
+	// FileExists is reported by Exists for every file.
+	FileExists bool
+}
+
+func (f MockFileHandler) MkDirAll(path string, perm os.FileMode) error {
+	if f.ShouldFailMkDirAll {
+		return fmt.Errorf("MkDirAll failed")
+	}
+
+	return nil
+}
+
+func (f MockFileHandler) Create(name string) (io.WriteCloser, error) {
+	if f.ShouldFailCreate {
+		return nil, fmt.Errorf("Create failed")
+	}
+
+	if f.ShouldFailWrite {
+		return nopCloser{MockFailingWriter{}}, nil
+	}
+
+	if f.ShouldFailClose {
+		return MockFailingCloser{io.Discard}, nil
+	}
+
+	return nopCloser{io.Discard}, nil
+}
+
+func (f MockFileHandler) OpenAppend(name string) (io.WriteCloser, int64, error) {
+	if f.ShouldFailAppend {
+		return nil, 0, fmt.Errorf("OpenAppend failed")
+	}
+
+	return nopCloser{io.Discard}, 0, nil
+}
+
+func (f MockFileHandler) Open(name string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func (f MockFileHandler) Remove(name string) error {
+	return nil
+}
+
+func (f MockFileHandler) Rename(oldName string, newName string) error {
+	return nil
+}
+
+func (f MockFileHandler) Exists(name string) (bool, error) {
+	if f.ShouldFailExists {
+		return false, fmt.Errorf("Exists failed")
+	}
+
+	return f.FileExists, nil
+}
+
+// MockFlakyFileHandler fails to create the output directory and file a number of
+// times before succeeding.
+type MockFlakyFileHandler struct {
+	MockFileHandler
+	MkDirAllFailures int
+	CreateFailures   int
+	AppendFailures   int
+	Calls            int
+	DirPerm          os.FileMode
+}
+
+func (f *MockFlakyFileHandler) MkDirAll(path string, perm os.FileMode) error {
+	f.Calls++
+	f.DirPerm = perm
+	if f.MkDirAllFailures > 0 {
+		f.MkDirAllFailures--
+		return fmt.Errorf("MkDirAll failed")
+	}
+
+	return nil
+}
+
+func (f *MockFlakyFileHandler) Create(name string) (io.WriteCloser, error) {
+	f.Calls++
+	if f.CreateFailures > 0 {
+		f.CreateFailures--
+		return nil, fmt.Errorf("Create failed")
+	}
+
+	return nopCloser{io.Discard}, nil
+}
+
+func (f *MockFlakyFileHandler) OpenAppend(name string) (io.WriteCloser, int64, error) {
+	f.Calls++
+	if f.AppendFailures > 0 {
+		f.AppendFailures--
+		return nil, 0, fmt.Errorf("OpenAppend failed")
+	}
+
+	return nopCloser{io.Discard}, 0, nil
+}
+
+type MockFailingWriter struct{}
+
+func (w MockFailingWriter) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("file write failed")
+}
+
+type MockFailingCloser struct {
+	io.Writer
+}
+
+func (c MockFailingCloser) Close() error {
+	return fmt.Errorf("Close failed")
+}
+
+type MockFileWriter struct {
+	ShouldFail bool
+}
+
+func (w MockFileWriter) Write(row []string, writer RecordWriter) error {
+	if w.ShouldFail {
+		return fmt.Errorf("Write failed")
+	}
+
+	return nil
+}
+
+// mustParseColumns returns the columns for the given fields, failing the test if any of
+// them are invalid.
+func mustParseColumns(t testing.TB, fields ...string) []column {
+	t.Helper()
+
+	columns, err := parseColumns(strings.Join(fields, ","))
+	if err != nil {
+		t.Fatalf("Failed to parse fields: %v", err)
+	}
+
+	return columns
+}
+
+func TestCSVFileWriter_EscapesText(t *testing.T) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	// Text fields can contain the delimiter, quotes and line breaks.
+	record := []string{"Hello, world.", `She said "hi".`, "First line.\nSecond line.", "paragraph(2)"}
+	if err := (CSVFileWriter{}).Write(record, writer); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	writer.Flush()
+
+	expected := "\"Hello, world.\",\"She said \"\"hi\"\".\",\"First line.\nSecond line.\",paragraph(2)\n"
+	if buf.String() != expected {
+		t.Errorf("\nExpected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+
+	readRecord, err := csv.NewReader(&buf).Read()
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+
+	if !slices.Equal(readRecord, record) {
+		t.Errorf("Expected record %q, got %q", record, readRecord)
+	}
+}
+
+func TestAlwaysQuoteWriter(t *testing.T) {
+	var buf bytes.Buffer
+	writer := newAlwaysQuoteWriter(&buf, ';', false)
+
+	records := [][]string{{"plain", `say "hi"`, "a;b", ""}, {"line\nbreak", "x"}}
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+
+	expected := "\"plain\";\"say \"\"hi\"\"\";\"a;b\";\"\"\n\"line\nbreak\";\"x\"\n"
+	if buf.String() != expected {
+		t.Errorf("\nExpected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+
+	// The output is still valid CSV that reads back to the same records.
+	reader := csv.NewReader(&buf)
+	reader.Comma = ';'
+	reader.FieldsPerRecord = -1
+	readRecords, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+
+	if !slices.EqualFunc(readRecords, records, slices.Equal[[]string]) {
+		t.Errorf("Expected records %q, got %q", records, readRecords)
+	}
+}
+
+func TestWriteData_MultiByteUTF8(t *testing.T) {
+	for _, alwaysQuote := range []bool{false, true} {
+		t.Run(fmt.Sprintf("alwaysquote=%v", alwaysQuote), func(t *testing.T) {
+			opts := Options{Rows: 50, Fields: "emoji,id", Delimiter: "§", Workers: 1, Seed: 1, AlwaysQuote: alwaysQuote}
+
+			var buf bytes.Buffer
+			if err := (CSVDataGenerator{}).writeData(context.Background(), &buf, false, opts, CSVFileWriter{}); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if !utf8.Valid(buf.Bytes()) {
+				t.Fatalf("Expected the output to be valid UTF-8, got %q", buf.String())
+			}
+
+			reader := csv.NewReader(&buf)
+			reader.Comma = '§'
+			records, err := reader.ReadAll()
+			if err != nil {
+				t.Fatalf("Failed to read CSV: %v", err)
+			}
+
+			generator := newRowGenerator(opts, mustParseColumns(t, "emoji", "id"), 0)
+			for i, record := range records[1:] {
+				if expected := generator.generateRow(int64(i)); !slices.Equal(record, expected) {
+					t.Errorf("Expected row %d to read back as %q, got %q", i, expected, record)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteData_MaxBytes(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator DataGenerator
+		opts      Options
+	}{
+		{
+			name:      "CSV",
+			generator: CSVDataGenerator{},
+			opts:      Options{Fields: "name,email", Delimiter: ","},
+		},
+		{
+			name:      "CSV always quoted",
+			generator: CSVDataGenerator{},
+			opts:      Options{Fields: "name,email", Delimiter: ",", AlwaysQuote: true},
+		},
+		{
+			name:      "JSON",
+			generator: JSONDataGenerator{},
+			opts:      Options{Fields: "name,email"},
+		},
+		{
+			name:      "Fixed width",
+			generator: FixedWidthDataGenerator{},
+			opts:      Options{Fields: "name,email", Widths: "20,40"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.Rows = 1
+			opts.MaxBytes = 10_000
+			opts.Workers = 4
+			opts.Seed = 1
+
+			var buf bytes.Buffer
+			if err := tt.generator.writeData(context.Background(), &buf, false, opts, CSVFileWriter{}); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			data := buf.String()
+			if !strings.HasSuffix(data, "\n") {
+				t.Fatalf("Expected the output to end with a complete row, got %q", data[max(len(data)-50, 0):])
+			}
+
+			lastRow := data[strings.LastIndex(data[:len(data)-1], "\n")+1:]
+			if len(data) < 10_000 || len(data)-len(lastRow) >= 10_000 {
+				t.Errorf("Expected the output to cross 10000 bytes on its last row, got %d bytes with a last row of %d", len(data), len(lastRow))
+			}
+		})
+	}
+}
+
+func TestAppendFixedWidthRow(t *testing.T) {
+	tests := []struct {
+		name     string
+		row      []string
+		widths   []int
+		crlf     bool
+		expected string
+	}{
+		{
+			name:     "Padded",
+			row:      []string{"Jane", "42"},
+			widths:   []int{6, 4},
+			expected: "Jane  42  \n",
+		},
+		{
+			name:     "Truncated",
+			row:      []string{"Jane Doe", "1234"},
+			widths:   []int{4, 2},
+			expected: "Jane12\n",
+		},
+		{
+			name:     "Empty value",
+			row:      []string{"", "x"},
+			widths:   []int{3, 1},
+			expected: "   x\n",
+		},
+		{
+			name:     "Multi-byte characters",
+			row:      []string{"Zoë Ørsted", "日本語"},
+			widths:   []int{3, 5},
+			expected: "Zoë日本語  \n",
+		},
+		{
+			name:     "CRLF",
+			row:      []string{"a"},
+			widths:   []int{2},
+			crlf:     true,
+			expected: "a \r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(appendFixedWidthRow(nil, tt.row, tt.widths, tt.crlf)); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFixedWidthWriteData_Alignment(t *testing.T) {
+	opts := Options{Rows: 200, Fields: "id,name,email,address", Format: "fixed", Widths: "4,12,20,30", NullRate: 0.2, Workers: 1, Seed: 1}
+
+	var buf bytes.Buffer
+	if err := (FixedWidthDataGenerator{}).writeData(context.Background(), &buf, false, opts, CSVFileWriter{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 200 {
+		t.Fatalf("Expected 200 lines without a header, got %d", len(lines))
+	}
+
+	generator := newRowGenerator(opts, mustParseColumns(t, "id", "name", "email", "address"), 0)
+	for i, line := range lines {
+		if length := utf8.RuneCountInString(line); length != 66 {
+			t.Fatalf("Expected line %d to be 66 characters, got %d: %q", i, length, line)
+		}
+
+		// Each column starts at the sum of the widths before it.
+		runes := []rune(line)
+		row := generator.generateRow(int64(i))
+		for col, bounds := range [][2]int{{0, 4}, {4, 16}, {16, 36}, {36, 66}} {
+			width := bounds[1] - bounds[0]
+			value := []rune(row[col])
+			expected := fmt.Sprintf("%-*s", width, string(value[:min(len(value), width)]))
+			if got := string(runes[bounds[0]:bounds[1]]); got != expected {
+				t.Errorf("Expected column %d of line %d to be %q, got %q", col, i, expected, got)
+			}
+		}
+	}
+}
+
+func TestOSFileHandler_OpenAppend(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "append.csv")
+	fileHandler := OSFileHandler{}
+
+	for _, expectedSize := range []int64{0, 4} {
+		file, size, err := fileHandler.OpenAppend(filePath)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		if size != expectedSize {
+			t.Errorf("Expected existing size %d, got %d", expectedSize, size)
+		}
+
+		file.Write([]byte("data"))
+		file.Close()
+	}
+}
+
+func TestOSFileHandler_Exists(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "exists.csv")
+	fileHandler := OSFileHandler{}
+
+	if exists, err := fileHandler.Exists(filePath); err != nil || exists {
+		t.Errorf("Expected the file not to exist, got %v (%v)", exists, err)
+	}
+
+	if err := os.WriteFile(filePath, []byte("data"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if exists, err := fileHandler.Exists(filePath); err != nil || !exists {
+		t.Errorf("Expected the file to exist, got %v (%v)", exists, err)
+	}
+}
+
+func TestGenerateRow_SameSeed(t *testing.T) {
+	fieldSlice := validFieldNames()
+	opts := Options{DateFormat: "2006-01-02", BoolFormat: "true/false", Seed: 42}
+	first := newRowGenerator(opts, mustParseColumns(t, fieldSlice...), 0)
+	second := newRowGenerator(opts, mustParseColumns(t, fieldSlice...), 0)
+
+	for i := 0; i < 10; i++ {
+		firstRow := strings.Join(first.generateRow(int64(i)), ",")
+		secondRow := strings.Join(second.generateRow(int64(i)), ",")
+		if firstRow != secondRow {
+			t.Errorf("\nRow %d differs between fakers with the same seed.\nFirst:\n%s\nSecond:\n%s", i, firstRow, secondRow)
+		}
+	}
+}
+
+func TestGenerateRows_ReproducibleWithWorkers(t *testing.T) {
+	fieldSlice := []string{"name", "age", "city"}
+	opts := Options{Rows: 200, Workers: 4, Seed: 7}
+
+	generateAll := func() []string {
+		done := make(chan struct{})
+		defer close(done)
+
+		var rows []string
+		for row := range generateRows(opts, mustParseColumns(t, fieldSlice...), nil, done) {
+			rows = append(rows, strings.Join(row, ","))
+		}
+
+		return rows
+	}
+
+	first := generateAll()
+	second := generateAll()
+	for idx := range first {
+		if first[idx] != second[idx] {
+			t.Errorf("\nRow %d differs between runs with the same seed.\nFirst:\n%s\nSecond:\n%s", idx, first[idx], second[idx])
+		}
+	}
+}
+
+func TestGenerateRows_RecycledRows(t *testing.T) {
+	opts := Options{Rows: 5000, Workers: 3, Seed: 7}
+	columns := mustParseColumns(t, "id,name,age,city")
+
+	generateAll := func(recycle rowRecycler) []string {
+		done := make(chan struct{})
+		defer close(done)
+
+		var rows []string
+		for row := range generateRows(opts, columns, recycle, done) {
+			rows = append(rows, strings.Join(row, ","))
+			recycle.add(row)
+		}
+
+		return rows
+	}
+
+	expected := generateAll(nil)
+	recycled := generateAll(newRowRecycler(opts.Workers))
+	if !slices.Equal(recycled, expected) {
+		t.Errorf("Expected recycling rows not to change the generated rows")
+	}
+}
+
+func TestGenerateRow_DistinctUUIDs(t *testing.T) {
+	generator := newRowGenerator(Options{Seed: 1}, mustParseColumns(t, "uuid"), 0)
+	seen := map[string]bool{}
+
+	for i := 0; i < 1000; i++ {
+		uuid := generator.generateRow(0)[0]
+		if seen[uuid] {
+			t.Fatalf("UUID %s generated for more than one row", uuid)
+		}
+		seen[uuid] = true
+	}
+}
+
+func TestGenerateRows_DistinctSSNs(t *testing.T) {
+	opts := Options{Rows: 100000, Workers: 4, Seed: 1}
+	done := make(chan struct{})
+	defer close(done)
+
+	seen := map[string]bool{}
+	for row := range generateRows(opts, mustParseColumns(t, "ssn"), nil, done) {
+		ssn := row[0]
+		if len(ssn) != 11 || ssn[0] != '9' || ssn[3] != '-' || ssn[6] != '-' {
+			t.Fatalf("Expected an SSN formatted as 9XX-XX-XXXX, got %q", ssn)
+		}
+		if seen[ssn] {
+			t.Fatalf("SSN %s generated for more than one row", ssn)
+		}
+		seen[ssn] = true
+	}
+}
+
+func TestGenerateRows_SeededField(t *testing.T) {
+	// collect returns the values of each column generated with the given options.
+	collect := func(opts Options, fields ...string) [][]string {
+		done := make(chan struct{})
+		defer close(done)
+
+		values := make([][]string, len(fields))
+		for row := range generateRows(opts, mustParseColumns(t, fields...), nil, done) {
+			for i, value := range row {
+				values[i] = append(values[i], value)
+			}
+		}
+
+		return values
+	}
+
+	first := collect(Options{Rows: 20, Workers: 1, Seed: 1}, "email@42", "age")
+	second := collect(Options{Rows: 20, Workers: 3, Seed: 2}, "name", "age", "email@42", "int(1,1000)@7")
+	third := collect(Options{Rows: 20, Workers: 2, Seed: 3}, "int(1,1000)@7")
+
+	if !slices.Equal(first[0], second[2]) {
+		t.Errorf("Expected the seeded field to be stable across runs\nGot:\n%v\n%v", first[0], second[2])
+	}
+
+	if !slices.Equal(second[3], third[0]) {
+		t.Errorf("Expected the seeded parameterized field to be stable across runs\nGot:\n%v\n%v", second[3], third[0])
+	}
+
+	if slices.Equal(first[1], second[1]) {
+		t.Errorf("Expected the unseeded field to change with the seed, got %v for both", first[1])
+	}
+}
+
+func TestGenerateRows_SeededPairedFieldIgnoresSeed(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []string
+	}{
+		{name: "Company with an email", fields: []string{"company@5", "email"}},
+		{name: "Age with a birthdate", fields: []string{"age@5", "birthdate"}},
+		{name: "Password with its hash", fields: []string{"password@5", "passwordhash"}},
+		{name: "Credit card with a CVV", fields: []string{"creditcard@5", "cvv"}},
+		{name: "Department with a job title", fields: []string{"department@5", "jobTitle"}},
+		{name: "Time zone with a city", fields: []string{"timezone@5", "city"}},
+		{name: "Time zone abbreviation with a state", fields: []string{"timezoneabbr@5", "state"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// collect returns the values of the seeded column generated with the given seed.
+			collect := func(seed int) []string {
+				done := make(chan struct{})
+				defer close(done)
+
+				var values []string
+				for row := range generateRows(Options{Rows: 50, Workers: 1, Seed: seed}, mustParseColumns(t, tt.fields...), nil, done) {
+					values = append(values, row[0])
+				}
+				return values
+			}
+
+			first, second := collect(1), collect(2)
+			if !slices.Equal(first, second) {
+				t.Errorf("Expected the seeded column to be the same for any seed\nGot:\n%v\n%v", first, second)
+			}
+		})
+	}
+}
+
+func TestGenerateRow_SeededFieldsShareBaseFields(t *testing.T) {
+	generator := newRowGenerator(Options{Seed: 1}, mustParseColumns(t, "name@5", "email@5", "name"), 0)
+
+	for i := int64(0); i < 100; i++ {
+		row := generator.generateRow(i)
+		first, last, _ := strings.Cut(strings.ToLower(row[0]), " ")
+		if !strings.HasPrefix(row[1], first+"."+last+"@") {
+			t.Fatalf("Expected fields with the same seed to share a name, got %v", row)
+		}
+	}
+}
+
+func TestCutSeed(t *testing.T) {
+	tests := []struct {
+		userField     string
+		expectedField string
+		expectedSeed  int
+		expectedError string
+	}{
+		{userField: "email", expectedField: "email"},
+		{userField: "email@42", expectedField: "email", expectedSeed: 42},
+		{userField: "int(1,10)@7", expectedField: "int(1,10)", expectedSeed: 7},
+		{userField: "enum(a@b,c)", expectedField: "enum(a@b,c)"},
+		{userField: "email@", expectedError: `seed must be a positive integer: ""`},
+		{userField: "email@0", expectedError: `seed must be a positive integer: "0"`},
+		{userField: "email@x", expectedError: `seed must be a positive integer: "x"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.userField, func(t *testing.T) {
+			field, seed, err := cutSeed(tt.userField)
+			if tt.expectedError != "" {
+				if err == nil || err.Error() != tt.expectedError {
+					t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
+				}
+				return
+			}
+
+			if err != nil || field != tt.expectedField || seed != tt.expectedSeed {
+				t.Errorf("Expected %q and %d, got %q and %d (%v)", tt.expectedField, tt.expectedSeed, field, seed, err)
+			}
+		})
+	}
+}
+
+func TestCutEmptyRate(t *testing.T) {
+	tests := []struct {
+		userField     string
+		expectedField string
+		expectedRate  float64
+		expectedOK    bool
+		expectedError string
+	}{
+		{userField: "email", expectedField: "email"},
+		{userField: "email?0.3", expectedField: "email", expectedRate: 0.3, expectedOK: true},
+		{userField: "email?0", expectedField: "email", expectedOK: true},
+		{userField: "int(1,10)?1", expectedField: "int(1,10)", expectedRate: 1, expectedOK: true},
+		{userField: "template({{email}}?)", expectedField: "template({{email}}?)"},
+		{userField: "email?", expectedError: `empty rate must be between 0 and 1: ""`},
+		{userField: "email?1.5", expectedError: `empty rate must be between 0 and 1: "1.5"`},
+		{userField: "email?x", expectedError: `empty rate must be between 0 and 1: "x"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.userField, func(t *testing.T) {
+			field, rate, ok, err := cutEmptyRate(tt.userField)
+			if tt.expectedError != "" {
+				if err == nil || err.Error() != tt.expectedError {
+					t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
+				}
+				return
+			}
+
+			if err != nil || field != tt.expectedField || rate != tt.expectedRate || ok != tt.expectedOK {
+				t.Errorf("Expected %q, %v and %v, got %q, %v and %v (%v)", tt.expectedField, tt.expectedRate, tt.expectedOK, field, rate, ok, err)
+			}
+		})
+	}
+}
+
+func TestCutTransform(t *testing.T) {
+	tests := []struct {
+		userField     string
+		expectedField string
+		input         string
+		expected      string
+		expectedError string
+	}{
+		{userField: "email", expectedField: "email"},
+		{userField: "email:upper", expectedField: "email", input: "Jane.Doe@example.com", expected: "JANE.DOE@EXAMPLE.COM"},
+		{userField: "email:lower", expectedField: "email", input: "Jane.Doe@example.com", expected: "jane.doe@example.com"},
+		{userField: "lastName:title", expectedField: "lastName", input: "mcDONALD", expected: "Mcdonald"},
+		{userField: "enum(a:1,b:2):upper", expectedField: "enum(a:1,b:2)", input: "a", expected: "A"},
+		{userField: "enum(a:1,b:2)", expectedField: "enum(a:1,b:2)"},
+		{userField: "email:", expectedError: `unknown transform "", supported transforms are: lower, title, upper`},
+		{userField: "email:shout", expectedError: `unknown transform "shout", supported transforms are: lower, title, upper`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.userField, func(t *testing.T) {
+			field, transform, err := cutTransform(tt.userField)
+			if tt.expectedError != "" {
+				if err == nil || err.Error() != tt.expectedError {
+					t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
+				}
+				return
+			}
+
+			if err != nil || field != tt.expectedField {
+				t.Fatalf("Expected %q, got %q (%v)", tt.expectedField, field, err)
+			}
+
+			if tt.input == "" {
+				if transform != nil {
+					t.Errorf("Expected no transform for %q", tt.userField)
+				}
+				return
+			}
+
+			if got := transform(tt.input); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTitleCase(t *testing.T) {
+	tests := map[string]string{
+		"":                  "",
+		"jane doe":          "Jane Doe",
+		"JANE  DOE":         "Jane  Doe",
+		"élodie o'brien":    "Élodie O'brien",
+		"tab\tand\nnewline": "Tab\tAnd\nNewline",
+	}
+
+	for input, expected := range tests {
+		if got := titleCase(input); got != expected {
+			t.Errorf("Expected %q for %q, got %q", expected, input, got)
+		}
+	}
+}
+
+func TestGenerateRow_Transforms(t *testing.T) {
+	// Columns with the same seed share their values, so each transform is applied to the
+	// same sentence.
+	columns := mustParseColumns(t, "sentence(4)@5", "sentence(4):upper@5", "sentence(4):lower@5", "sentence(4):title?0@5")
+	generator := newRowGenerator(Options{Seed: 1}, columns, 0)
+
+	for i := int64(0); i < 20; i++ {
+		row := generator.generateRow(i)
+		expected := []string{row[0], strings.ToUpper(row[0]), strings.ToLower(row[0]), titleCase(row[0])}
+		if !slices.Equal(row, expected) {
+			t.Errorf("Expected %q, got %q", expected, row)
+		}
+	}
+}
+
+func TestCanonicalFieldCase(t *testing.T) {
+	tests := []struct {
+		userField string
+		expected  string
+	}{
+		{userField: "name", expected: "name"},
+		{userField: "NAME", expected: "name"},
+		{userField: "FirstName", expected: "firstName"},
+		{userField: "INT(1,10)", expected: "int(1,10)"},
+		{userField: "Email?0.5@3", expected: "email?0.5@3"},
+		{userField: "ENUM(A,b)", expected: "enum(A,b)"},
+		{userField: "Nickname", expected: "Nickname"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.userField, func(t *testing.T) {
+			if got := canonicalFieldCase(tt.userField); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGenerateRows_EmptyRate(t *testing.T) {
+	const rows = 10000
+	opts := Options{Rows: rows, Workers: 2, Seed: 1}
+	columns := mustParseColumns(t, "name?0.3,email?0.5@4,city")
+
+	done := make(chan struct{})
+	defer close(done)
+
+	empty := make([]int, len(columns))
+	for row := range generateRows(opts, columns, nil, done) {
+		for i, value := range row {
+			if value == "" {
+				empty[i]++
+			}
+		}
+	}
+
+	if columns[1].name != "email" || columns[1].emptyRate != 0.5 || columns[1].seed != 4 {
+		t.Fatalf("Expected an email column with an empty rate of 0.5 and a seed of 4, got %+v", columns[1])
+	}
+
+	if rate := float64(empty[0]) / rows; rate < 0.28 || rate > 0.32 {
+		t.Errorf("Expected name to be empty about 30%% of the time, got %.3f", rate)
+	}
+	if rate := float64(empty[1]) / rows; rate < 0.47 || rate > 0.53 {
+		t.Errorf("Expected email to be empty about 50%% of the time, got %.3f", rate)
+	}
+	if empty[2] != 0 {
+		t.Errorf("Expected city to always be set, got %d empty values", empty[2])
+	}
+
+	// An annotated rate replaces -nullrate for the column, so a rate of 0 keeps it set.
+	if rate := mustParseColumns(t, "age?0")[0].nullRate(Options{NullRate: 1}); rate != 0 {
+		t.Errorf("Expected age?0 to never be empty, got a rate of %v", rate)
+	}
+}
+
+func TestGenerateRows_FoodFields(t *testing.T) {
+	fieldSlice := []string{"food", "fruit", "vegetable", "dessert"}
+	generator := newRowGenerator(Options{Seed: 1}, mustParseColumns(t, fieldSlice...), 0)
+
+	values := make([]map[string]bool, len(fieldSlice))
+	for i := range values {
+		values[i] = map[string]bool{}
+	}
+	for i := int64(0); i < 20; i++ {
+		for j, value := range generator.generateRow(i) {
+			values[j][value] = true
+		}
+	}
+
+	for i, field := range fieldSlice {
+		if len(values[i]) < 10 {
+			t.Errorf("Expected %s to vary between rows, got %d distinct values in 20 rows", field, len(values[i]))
+		}
+	}
+}
+
+func TestGenerateRow_TimeZoneMatchesState(t *testing.T) {
+	generator := newRowGenerator(Options{Seed: 1}, mustParseColumns(t, "state", "timezone", "timezoneabbr"), 0)
+
+	for i := int64(0); i < 200; i++ {
+		row := generator.generateRow(i)
+		zone, ok := stateTimeZones[row[0]]
+		if !ok {
+			t.Fatalf("Expected state %q to have a time zone", row[0])
+		}
+
+		if row[1] != zone.name || row[2] != zone.abbr {
+			t.Errorf("Expected %s to be in %s (%s), got %s (%s)", row[0], zone.name, zone.abbr, row[1], row[2])
+		}
+	}
+}
+
+// ageOn returns the age on now of someone born on birthdate, to check the birthdates
+// drawn by birthdateForAge.
+func ageOn(birthdate time.Time, now time.Time) int {
+	age := now.Year() - birthdate.Year()
+	if now.Month() < birthdate.Month() || (now.Month() == birthdate.Month() && now.Day() < birthdate.Day()) {
+		age--
+	}
+
+	return age
+}
+
+func TestGenerateRow_AgeMatchesBirthdate(t *testing.T) {
+	generator := newRowGenerator(Options{DateFormat: "2006-01-02", IntFormat: "%d", Seed: 1}, mustParseColumns(t, "age", "birthdate"), 0)
+
+	for i := int64(0); i < 500; i++ {
+		row := generator.generateRow(i)
+		birthdate, err := time.Parse("2006-01-02", row[1])
+		if err != nil {
+			t.Fatalf("Failed to parse birthdate %q: %v", row[1], err)
+		}
+
+		if expected := strconv.Itoa(ageOn(birthdate, time.Now())); row[0] != expected {
+			t.Errorf("Expected someone born on %s to be %s, got %s", row[1], expected, row[0])
+		}
+	}
+}
+
+func TestGenerateRow_PasswordHash(t *testing.T) {
+	hashPattern := regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+	t.Run("Matches the password", func(t *testing.T) {
+		generator := newRowGenerator(Options{Seed: 1}, mustParseColumns(t, "password", "passwordhash"), 0)
+		for i := int64(0); i < 100; i++ {
+			row := generator.generateRow(i)
+			if len(row[0]) != passwordLength {
+				t.Errorf("Expected a %d character password, got %q", passwordLength, row[0])
+			}
+
+			hash := sha256.Sum256([]byte(row[0]))
+			if expected := hex.EncodeToString(hash[:]); row[1] != expected {
+				t.Errorf("Expected the hash of %q to be %s, got %s", row[0], expected, row[1])
+			}
+		}
+	})
+
+	t.Run("On its own", func(t *testing.T) {
+		generator := newRowGenerator(Options{Seed: 1}, mustParseColumns(t, "passwordhash"), 0)
+		seen := map[string]bool{}
+		for i := int64(0); i < 100; i++ {
+			hash := generator.generateRow(i)[0]
+			if !hashPattern.MatchString(hash) {
+				t.Errorf("Expected a hex encoded SHA-256 hash, got %q", hash)
+			}
+			seen[hash] = true
+		}
+
+		if len(seen) != 100 {
+			t.Errorf("Expected every row to hash a different password, got %d distinct hashes", len(seen))
+		}
+	})
+}
+
+func TestGenerateRow_DepartmentMatchesJobTitle(t *testing.T) {
+	generator := newRowGenerator(Options{Seed: 1}, mustParseColumns(t, "jobTitle", "department"), 0)
+
+	seen := map[string]bool{}
+	for i := int64(0); i < 1000; i++ {
+		row := generator.generateRow(i)
+		seen[row[1]] = true
+
+		expected, ok := titleDepartments[row[0]]
+		if !ok {
+			expected = departments
+		}
+		if !slices.Contains(expected, row[1]) {
+			t.Errorf("Expected a %s to work in one of %v, got %q", row[0], expected, row[1])
+		}
+	}
+
+	if len(seen) != len(departments) {
+		t.Errorf("Expected every department to be generated, got %v", seen)
+	}
+}
+
+// luhnValid reports whether number passes the Luhn checksum of card numbers.
+func luhnValid(number string) bool {
+	sum := 0
+	for i := range number {
+		digit := int(number[len(number)-1-i] - '0')
+		if i%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+
+	return sum%10 == 0
+}
+
+func TestGenerateRow_CreditCard(t *testing.T) {
+	expiryPattern := regexp.MustCompile(`^(0[1-9]|1[0-2])/[0-9]{2}$`)
+	cvvPattern := regexp.MustCompile(`^[0-9]{3,4}$`)
+
+	// Every combination with creditcard shares the row's card, so they agree with each
+	// other for the same seed.
+	full := newRowGenerator(Options{Seed: 1}, mustParseColumns(t, "creditcard", "ccexpiry", "cvv"), 0)
+	withExpiry := newRowGenerator(Options{Seed: 1}, mustParseColumns(t, "creditcard", "ccexpiry"), 0)
+	withCvv := newRowGenerator(Options{Seed: 1}, mustParseColumns(t, "cvv", "creditcard"), 0)
+
+	for i := int64(0); i < 100; i++ {
+		row := full.generateRow(i)
+		if !luhnValid(row[0]) {
+			t.Errorf("Expected card number %q to pass the Luhn check", row[0])
+		}
+		if !expiryPattern.MatchString(row[1]) {
+			t.Errorf("Expected an MM/YY expiry, got %q", row[1])
+		}
+		if !cvvPattern.MatchString(row[2]) {
+			t.Errorf("Expected a 3 or 4 digit CVV, got %q", row[2])
+		}
+
+		if got := withExpiry.generateRow(i); got[0] != row[0] || got[1] != row[1] {
+			t.Errorf("Expected card %v to have the expiry of %v", got, row)
+		}
+		if got := withCvv.generateRow(i); got[1] != row[0] || got[0] != row[2] {
+			t.Errorf("Expected card %v to have the CVV of %v", got, row)
+		}
+	}
+}
+
+func TestAgeOn(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		birthdate time.Time
+		expected  int
+	}{
+		{birthdate: time.Date(2000, time.March, 15, 0, 0, 0, 0, time.UTC), expected: 24},
+		{birthdate: time.Date(2000, time.March, 16, 0, 0, 0, 0, time.UTC), expected: 23},
+		{birthdate: time.Date(2000, time.February, 29, 0, 0, 0, 0, time.UTC), expected: 24},
+		{birthdate: time.Date(2000, time.December, 31, 0, 0, 0, 0, time.UTC), expected: 23},
+	}
+
+	for _, tt := range tests {
+		if age := ageOn(tt.birthdate, now); age != tt.expected {
+			t.Errorf("Expected someone born on %s to be %d, got %d", tt.birthdate.Format(time.DateOnly), tt.expected, age)
+		}
+	}
+}
+
+func TestGenerateRow_Birthdate(t *testing.T) {
+	tests := []struct {
+		name       string
+		dateFormat string
+	}{
+		{
+			name:       "Default date format",
+			dateFormat: "2006-01-02",
+		},
+		{
+			name:       "Custom date format",
+			dateFormat: "02/01/2006",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{DateFormat: tt.dateFormat, Seed: 1}
+			birthdate := newRowGenerator(opts, mustParseColumns(t, "birthdate"), 0).generateRow(0)[0]
+
+			if _, err := time.Parse(tt.dateFormat, birthdate); err != nil {
+				t.Errorf("Expected birthdate %q to match format %q: %v", birthdate, tt.dateFormat, err)
+			}
+
+			// gofakeit picks a year up to the current one, so the exact date isn't
+			// asserted, only that the same seed reproduces it.
+			if again := newRowGenerator(opts, mustParseColumns(t, "birthdate"), 0).generateRow(0)[0]; again != birthdate {
+				t.Errorf("Expected the same birthdate for the same seed, got %q and %q", birthdate, again)
+			}
+		})
+	}
+}
+
+func TestGenerateRow_NullRate(t *testing.T) {
+	fieldSlice := []string{"name", "age", "email", "city"}
+
+	tests := []struct {
+		name         string
+		nullRate     float64
+		minEmptyRate float64
+		maxEmptyRate float64
+	}{
+		{
+			name:         "Rate of 0 leaves every value populated",
+			nullRate:     0,
+			minEmptyRate: 0,
+			maxEmptyRate: 0,
+		},
+		{
+			name:         "Rate of 1 empties every value",
+			nullRate:     1,
+			minEmptyRate: 1,
+			maxEmptyRate: 1,
+		},
+		{
+			name:         "Rate of 0.5 empties roughly half the values",
+			nullRate:     0.5,
+			minEmptyRate: 0.45,
+			maxEmptyRate: 0.55,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator := newRowGenerator(Options{NullRate: tt.nullRate, Seed: 1}, mustParseColumns(t, fieldSlice...), 0)
+
+			emptyCells, totalCells := 0, 0
+			for i := 0; i < 1000; i++ {
+				for _, value := range generator.generateRow(0) {
+					if value == "" {
+						emptyCells++
+					}
+					totalCells++
+				}
+			}
+
+			emptyRate := float64(emptyCells) / float64(totalCells)
+			if emptyRate < tt.minEmptyRate || emptyRate > tt.maxEmptyRate {
+				t.Errorf("Expected empty rate between %v and %v, got %v", tt.minEmptyRate, tt.maxEmptyRate, emptyRate)
+			}
+		})
+	}
+}
+
+func TestGenerateRow_NullRateIsSeeded(t *testing.T) {
+	fieldSlice := []string{"name", "age", "email", "city"}
+	opts := Options{NullRate: 0.3, Seed: 5}
+	first := newRowGenerator(opts, mustParseColumns(t, fieldSlice...), 0)
+	second := newRowGenerator(opts, mustParseColumns(t, fieldSlice...), 0)
+
+	for i := 0; i < 100; i++ {
+		firstRow := strings.Join(first.generateRow(int64(i)), ",")
+		secondRow := strings.Join(second.generateRow(int64(i)), ",")
+		if firstRow != secondRow {
+			t.Errorf("\nRow %d differs between fakers with the same seed.\nFirst:\n%s\nSecond:\n%s", i, firstRow, secondRow)
+		}
+	}
+}
+
+func TestGenerateRow_IndependentOfBaseFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		fieldSlice []string
+		ageIndex   int
+	}{
+		{
+			name:       "Only age",
+			fieldSlice: []string{"age"},
+			ageIndex:   0,
+		},
+		{
+			name:       "Name and age",
+			fieldSlice: []string{"name", "age"},
+			ageIndex:   1,
+		},
+		{
+			name:       "Every base derived field and age",
+			fieldSlice: []string{"email", "address", "age", "latitude"},
+			ageIndex:   2,
+		},
+		{
+			name:       "Currency fields and age",
+			fieldSlice: []string{"currency", "age", "currencyname"},
+			ageIndex:   1,
+		},
+	}
+
+	var expectedAges []string
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator := newRowGenerator(Options{Seed: 1}, mustParseColumns(t, tt.fieldSlice...), 0)
+
+			var ages []string
+			for i := 0; i < 50; i++ {
+				ages = append(ages, generator.generateRow(0)[tt.ageIndex])
+			}
+
+			if expectedAges == nil {
+				expectedAges = ages
+			}
+
+			if strings.Join(ages, ",") != strings.Join(expectedAges, ",") {
+				t.Errorf("\nExpected ages:\n%v\nGot:\n%v", expectedAges, ages)
+			}
+		})
+	}
+}
+
+func TestNewRowGenerator_NeedsBaseFields(t *testing.T) {
+	if newRowGenerator(Options{}, mustParseColumns(t, "age", "uuid"), 0).needsBaseFields {
+		t.Errorf("Expected base fields not to be needed without a base derived field")
+	}
+
+	if !newRowGenerator(Options{}, mustParseColumns(t, "age", "city"), 0).needsBaseFields {
+		t.Errorf("Expected base fields to be needed with a base derived field")
+	}
+}
+
+func TestSplitFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   string
+		expected []string
+	}{
+		{
+			name:     "Plain fields",
+			fields:   "name,age",
+			expected: []string{"name", "age"},
+		},
+		{
+			name:     "Commas between parentheses",
+			fields:   "int(1,10),name,int(-5,5)",
+			expected: []string{"int(1,10)", "name", "int(-5,5)"},
+		},
+		{
+			name:     "Unclosed parentheses",
+			fields:   "int(1,10,name",
+			expected: []string{"int(1,10,name"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := splitFields(tt.fields)
+			if strings.Join(actual, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("Expected %q, got %q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+		format string
+	}{
+		{name: "CSV", fields: "name,age,email,address", format: "csv"},
+		{name: "JSON", fields: "name,age,email,address", format: "json"},
+		{name: "Varying lengths", fields: "sentence,int(1,1000000)", format: "csv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.Rows = 5000
+			opts.Fields = tt.fields
+			opts.Format = tt.format
+			opts.Seed = 1
+
+			size, _, err := estimateSize(opts)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := GenerateToWriter(&buf, opts); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			// The sample is small, so the estimate only has to be close to the real size.
+			actual := int64(buf.Len())
+			if size < actual*9/10 || size > actual*11/10 {
+				t.Errorf("Expected an estimate within 10%% of %d bytes, got %d", actual, size)
+			}
+		})
+	}
+}
+
+func TestEstimateSize_SameSizeRows(t *testing.T) {
+	for _, bom := range []bool{false, true} {
+		opts := DefaultOptions()
+		opts.Rows = 5000
+		opts.Fields = "int(7,7)"
+		opts.BOM = bom
+		opts.Seed = 1
+
+		size, _, err := estimateSize(opts)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		// Every row is the same, so the estimate is only off if the header is.
+		var buf bytes.Buffer
+		if err := GenerateToWriter(&buf, opts); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if size != int64(buf.Len()) {
+			t.Errorf("BOM %v: expected an estimate of %d bytes, got %d", bom, buf.Len(), size)
+		}
+	}
+}
+
+func TestFormatByteSize(t *testing.T) {
+	tests := []struct {
+		size     int64
+		expected string
+	}{
+		{size: 0, expected: "0 B"},
+		{size: 999, expected: "999 B"},
+		{size: 1500, expected: "1.5 kB"},
+		{size: 17_600_000, expected: "17.6 MB"},
+		{size: 2_000_000_000_000, expected: "2.0 TB"},
+	}
+
+	for _, tt := range tests {
+		if got := formatByteSize(tt.size); got != tt.expected {
+			t.Errorf("Expected %d bytes to be formatted as %q, got %q", tt.size, tt.expected, got)
+		}
+	}
+}
+
+func TestShuffleColumns(t *testing.T) {
+	opts := Options{Fields: "id,name,age,email,int(1,10),city", Format: "fixed", Widths: "1,2,3,4,5,6", Seed: 1}
+
+	shuffled := shuffleColumns(opts)
+	if again := shuffleColumns(opts); again.Fields != shuffled.Fields || again.Widths != shuffled.Widths {
+		t.Errorf("Expected the same seed to give the same order, got %q and %q", shuffled.Fields, again.Fields)
+	}
+
+	fields := splitFields(shuffled.Fields)
+	if sorted := slices.Sorted(slices.Values(fields)); !slices.Equal(sorted, []string{"age", "city", "email", "id", "int(1,10)", "name"}) {
+		t.Errorf("Expected the shuffled fields to be the selected fields, got %v", fields)
+	}
+
+	// Each width moves with its field.
+	widthOf := map[string]string{"id": "1", "name": "2", "age": "3", "email": "4", "int(1,10)": "5", "city": "6"}
+	for i, width := range strings.Split(shuffled.Widths, ",") {
+		if widthOf[fields[i]] != width {
+			t.Errorf("Expected %s to keep its width of %s, got %s", fields[i], widthOf[fields[i]], width)
+		}
+	}
+
+	orders := map[string]bool{}
+	for seed := 1; seed <= 20; seed++ {
+		opts.Seed = seed
+		orders[shuffleColumns(opts).Fields] = true
+	}
+	if len(orders) < 10 {
+		t.Errorf("Expected different seeds to give different orders, got %d orders from 20 seeds", len(orders))
+	}
+}
+
+func TestPreview_AllFields(t *testing.T) {
+	// Like other field names, all isn't case sensitive.
+	for _, fields := range []string{"all", "ALL", "All"} {
+		t.Run(fields, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.Fields = fields
+			opts.Seed = 1
+
+			var buf bytes.Buffer
+			if err := Preview(&buf, opts); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			records, err := csv.NewReader(&buf).ReadAll()
+			if err != nil {
+				t.Fatalf("Failed to read CSV: %v", err)
+			}
+
+			if expectedHeader := validFieldNames(); !slices.Equal(records[0], expectedHeader) {
+				t.Errorf("Expected the header to be every field:\n%v\nGot:\n%v", expectedHeader, records[0])
+			}
+			if len(records) != 2 || len(records[1]) != len(records[0]) {
+				t.Errorf("Expected a sample row with a value for every field, got %v", records[1:])
+			}
+		})
+	}
+}
+
+func TestDuplicateFields(t *testing.T) {
+	tests := []struct {
+		fields   string
+		expected []string
+	}{
+		{fields: "name,age,email", expected: nil},
+		{fields: "name,age,name", expected: []string{"name"}},
+		{fields: "age,name,age,age,name", expected: []string{"age", "name"}},
+		{fields: "int(1,5),int(1,6)", expected: nil},
+		{fields: "int(1,5),int(1,5)", expected: []string{"int(1,5)"}},
+		{fields: "email@1,email@2", expected: []string{"email"}},
+		{fields: "name,Name,NAME", expected: []string{"Name"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fields, func(t *testing.T) {
+			got := duplicateFields(tt.fields)
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewIntGenerator_StaysInRange(t *testing.T) {
+	generator := newRowGenerator(Options{Seed: 1}, mustParseColumns(t, "int(-3,3)"), 0)
+
+	for i := 0; i < 1000; i++ {
+		value, err := strconv.Atoi(generator.generateRow(0)[0])
+		if err != nil || value < -3 || value > 3 {
+			t.Fatalf("Expected an integer between -3 and 3, got %v (%v)", value, err)
+		}
+	}
+}
+
+func TestFormatInt(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{format: "", expected: "7"},
+		{format: "%03d", expected: "007"},
+		{format: "%d years", expected: "7 years"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			if got := formatInt(7, Options{IntFormat: tt.format}); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		name             string
+		defaultPrecision int
+		precision        int
+		expected         string
+	}{
+		{name: "Field default", defaultPrecision: 2, precision: -1, expected: "12.35"},
+		{name: "Precision overrides the default", defaultPrecision: 2, precision: 4, expected: "12.3457"},
+		{name: "No decimal places", defaultPrecision: 6, precision: 0, expected: "12"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatFloat(12.345678, tt.defaultPrecision, Options{Precision: tt.precision})
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewPriceGenerator_StaysInRange(t *testing.T) {
+	generator := newRowGenerator(Options{Seed: 1, Precision: -1}, mustParseColumns(t, "price(0.99,1.99)"), 0)
+
+	for i := 0; i < 1000; i++ {
+		value := generator.generateRow(0)[0]
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil || price < 0.99 || price > 1.99 {
+			t.Fatalf("Expected a price between 0.99 and 1.99, got %q (%v)", value, err)
+		}
+		if _, cents, _ := strings.Cut(value, "."); len(cents) != 2 {
+			t.Fatalf("Expected two decimal places, got %q", value)
+		}
+	}
+}
+
+func TestNewTimestampGenerator_StaysInRange(t *testing.T) {
+	generator := newRowGenerator(Options{Seed: 1}, mustParseColumns(t, "timestamp(2024-02-29,2024-03-01T06:00:00Z)"), 0)
+	start := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 1000; i++ {
+		value := generator.generateRow(int64(i))[0]
+		timestamp, err := time.Parse(time.RFC3339, value)
+		if err != nil || timestamp.Before(start) || timestamp.After(end) {
+			t.Fatalf("Expected an RFC 3339 timestamp between %v and %v, got %q (%v)", start, end, value, err)
+		}
+	}
+}
+
+func TestNewFromFileGenerator(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "categories.txt")
+	if err := os.WriteFile(path, []byte("Books\n\n  Garden \r\nToys\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write values file: %v", err)
+	}
+
+	generator := newRowGenerator(Options{Seed: 1}, mustParseColumns(t, "fromfile("+path+")"), 0)
+
+	var values []string
+	for i := int64(0); i < 4; i++ {
+		values = append(values, generator.generateRow(i)[0])
+	}
+
+	expectedValues := []string{"Garden", "Garden", "Garden", "Toys"}
+	if !slices.Equal(values, expectedValues) {
+		t.Errorf("Expected values %v, got %v", expectedValues, values)
+	}
+}
+
+func TestNewFromFileGenerator_Errors(t *testing.T) {
+	dir := t.TempDir()
+	emptyPath := filepath.Join(dir, "empty.txt")
+	if err := os.WriteFile(emptyPath, []byte("\n  \n"), 0o644); err != nil {
+		t.Fatalf("Failed to write values file: %v", err)
+	}
+	missingPath := filepath.Join(dir, "missing.txt")
+
+	tests := []struct {
+		name          string
+		params        string
+		expectedError string
+	}{
+		{name: "Empty path", params: " ", expectedError: "path cannot be empty"},
+		{name: "Missing file", params: missingPath, expectedError: "failed to read values: open " + missingPath + ": no such file or directory"},
+		{name: "File without values", params: emptyPath, expectedError: "file " + emptyPath + " has no values"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newFromFileGenerator(tt.params)
+			if err == nil || err.Error() != tt.expectedError {
+				t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestNewTemplateGenerator(t *testing.T) {
+	columns := mustParseColumns(t, "firstName,template({{firstName}} <{{email}}>),email")
+	generator := newRowGenerator(Options{Seed: 1}, columns, 0)
+
+	for i := int64(0); i < 5; i++ {
+		row := generator.generateRow(i)
+		if expected := row[0] + " <" + row[2] + ">"; row[1] != expected {
+			t.Errorf("Expected the template to combine the row's fields into %q, got %q", expected, row[1])
+		}
+	}
+
+	template := mustParseColumns(t, "template({{email}}{{if true}}{{firstName}}{{end}}{{email}})")[0]
+	if expectedReferences := []string{"email", "firstName"}; !slices.Equal(template.references, expectedReferences) {
+		t.Errorf("Expected references %v, got %v", expectedReferences, template.references)
+	}
+
+	// The base fields are drawn for a template even when none of the columns select them.
+	row := newRowGenerator(Options{Seed: 1}, mustParseColumns(t, "template({{city}}, {{state}})"), 0).generateRow(0)
+	if city, state, _ := strings.Cut(row[0], ", "); city == "" || state == "" {
+		t.Errorf("Expected a city and state, got %q", row[0])
+	}
+
+	// Fields called in defined templates are referenced too, so they have values.
+	template = mustParseColumns(t, `template({{define "person"}}{{firstName}}{{end}}{{block "job" .}}{{jobTitle}}{{end}} {{template "person"}})`)[0]
+	if expectedReferences := []string{"jobTitle", "firstName"}; !slices.Equal(template.references, expectedReferences) {
+		t.Errorf("Expected references %v, got %v", expectedReferences, template.references)
+	}
+	row = newRowGenerator(Options{Seed: 1}, []column{template}, 0).generateRow(0)
+	if job, firstName, _ := strings.Cut(row[0], " "); job == "" || firstName == "" {
+		t.Errorf("Expected a job title and first name, got %q", row[0])
+	}
+}
+
+func TestNewTemplateGenerator_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		params        string
+		expectedError string
+	}{
+		{name: "Unknown field", params: "{{firstName}} {{nickname}}", expectedError: `template: template:1: function "nickname" not defined`},
+		{name: "Parameterized field", params: "{{int}}", expectedError: `template: template:1: function "int" not defined`},
+		{name: "Unclosed action", params: "{{firstName", expectedError: "template: template:1: unclosed action"},
+		{name: "Unknown field in a defined template", params: `{{define "x"}}{{nickname}}{{end}}{{template "x"}}`, expectedError: `template: template:1: function "nickname" not defined`},
+		{name: "Failed execution", params: `{{index "abc" 10}}`, expectedError: `template: template:1:2: executing "template" at <index "abc" 10>: error calling index: index out of range: 10`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTemplateGenerator(tt.params)
+			if err == nil || err.Error() != tt.expectedError {
+				t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestNewSalaryGenerator_Distribution(t *testing.T) {
+	tests := []struct {
+		fields   string
+		minValue int
+		maxValue int
+	}{
+		{fields: "salary", minValue: defaultMinSalary, maxValue: defaultMaxSalary},
+		{fields: "salary(40000,60000)", minValue: 40000, maxValue: 60000},
+		{fields: "salary(50000,50000)", minValue: 50000, maxValue: 50000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fields, func(t *testing.T) {
+			generator := newRowGenerator(Options{Seed: 1}, mustParseColumns(t, tt.fields), 0)
+
+			const rows = 10000
+			lowerQuarter := tt.minValue + (tt.maxValue-tt.minValue)/4
+			inLowerQuarter := 0
+			for i := 0; i < rows; i++ {
+				value := generator.generateRow(int64(i))[0]
+				salary, err := strconv.Atoi(value)
+				if err != nil || salary < tt.minValue || salary > tt.maxValue {
+					t.Fatalf("Expected a whole salary between %d and %d, got %q", tt.minValue, tt.maxValue, value)
+				}
+				if salary <= lowerQuarter {
+					inLowerQuarter++
+				}
+			}
+
+			// Half of the values fall in the lowest quarter of the range, against a quarter
+			// for a uniform distribution.
+			if share := float64(inLowerQuarter) / rows; share < 0.45 {
+				t.Errorf("Expected about half the salaries in the lowest quarter of the range, got %.3f", share)
+			}
+		})
+	}
+}
+
+func TestSkewedNumber_WideRanges(t *testing.T) {
+	tests := []struct {
+		minValue int
+		maxValue int
+	}{
+		{minValue: 0, maxValue: math.MaxInt},
+		{minValue: math.MinInt, maxValue: 0},
+		{minValue: math.MinInt, maxValue: math.MaxInt},
+		{minValue: -10, maxValue: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d,%d", tt.minValue, tt.maxValue), func(t *testing.T) {
+			faker := gofakeit.New(1)
+			for i := 0; i < 1000; i++ {
+				if value := skewedNumber(faker, tt.minValue, tt.maxValue); value < tt.minValue || value > tt.maxValue {
+					t.Fatalf("Expected a value between %d and %d, got %d", tt.minValue, tt.maxValue, value)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateProgress(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          Options
+		expectedFirst string
+		expectedLast  string
+	}{
+		{
+			name:          "Default precision",
+			opts:          Options{Rows: 1000, Precision: -1, Seed: 1},
+			expectedFirst: "0.10%",
+			expectedLast:  "100.00%",
+		},
+		{
+			name:          "Custom precision",
+			opts:          Options{Rows: 3, Precision: 1, Seed: 1},
+			expectedFirst: "33.3%",
+			expectedLast:  "100.0%",
+		},
+		{
+			name:          "Single row",
+			opts:          Options{Rows: 1, Precision: -1, Seed: 1},
+			expectedFirst: "100.00%",
+			expectedLast:  "100.00%",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator := newRowGenerator(tt.opts, mustParseColumns(t, "progress"), 0)
+
+			if first := generator.generateRow(0)[0]; first != tt.expectedFirst {
+				t.Errorf("Expected the first row to be %s, got %s", tt.expectedFirst, first)
+			}
+			if last := generator.generateRow(tt.opts.Rows - 1)[0]; last != tt.expectedLast {
+				t.Errorf("Expected the last row to be %s, got %s", tt.expectedLast, last)
+			}
+		})
+	}
+}
+
+func TestGenerateJSONBlob_CSVRoundTrip(t *testing.T) {
+	for _, alwaysQuote := range []bool{false, true} {
+		t.Run(fmt.Sprintf("AlwaysQuote %v", alwaysQuote), func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.Rows = 100
+			opts.Fields = "id,jsonblob"
+			opts.AlwaysQuote = alwaysQuote
+			opts.Seed = 1
+			opts.Quiet = true
+
+			var buf bytes.Buffer
+			if err := (CSVDataGenerator{}).writeData(context.Background(), &buf, false, opts, CSVFileWriter{}); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			reader := csv.NewReader(&buf)
+			reader.FieldsPerRecord = 2
+			records, err := reader.ReadAll()
+			if err != nil {
+				t.Fatalf("Expected the embedded JSON to be escaped, got: %v", err)
+			}
+
+			for _, record := range records[1:] {
+				var blob struct {
+					Count   int    `json:"count"`
+					Enabled bool   `json:"enabled"`
+					Tag     string `json:"tag"`
+				}
+				decoder := json.NewDecoder(strings.NewReader(record[1]))
+				decoder.DisallowUnknownFields()
+				if err := decoder.Decode(&blob); err != nil {
+					t.Fatalf("Expected a JSON object, got %q: %v", record[1], err)
+				}
+
+				if blob.Count < 1 || blob.Count > 100 || blob.Tag == "" {
+					t.Errorf("Unexpected JSON object: %q", record[1])
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateHTTPStatus_Distribution(t *testing.T) {
+	generator := newRowGenerator(Options{Seed: 1}, mustParseColumns(t, "httpstatus"), 0)
+
+	const rows = 20000
+	counts := map[string]int{}
+	for i := 0; i < rows; i++ {
+		counts[generator.generateRow(int64(i))[0]]++
+	}
+
+	var totalWeight float32
+	weights := map[string]float32{}
+	for _, status := range httpStatusCodes {
+		weights[status.code] = status.weight
+		totalWeight += status.weight
+	}
+
+	for code := range counts {
+		if _, ok := weights[code]; !ok {
+			t.Errorf("Expected only the common status codes, got %q", code)
+		}
+	}
+
+	// Each code's share is within a few percent of its weight.
+	for code, weight := range weights {
+		expected := float64(weight / totalWeight)
+		share := float64(counts[code]) / rows
+		if math.Abs(share-expected) > 0.01+expected*0.1 {
+			t.Errorf("Expected about %.3f of the codes to be %s, got %.3f", expected, code, share)
+		}
+	}
+
+	if counts["200"] <= counts["404"] || counts["404"] <= counts["500"] {
+		t.Errorf("Expected more 200s than 404s and more 404s than 500s, got %d, %d and %d", counts["200"], counts["404"], counts["500"])
+	}
+}
+
+func TestNewEnumGenerator_Distribution(t *testing.T) {
+	generator := newRowGenerator(Options{Seed: 1}, mustParseColumns(t, "enum(active:70,inactive:20,pending:10)"), 0)
+
+	const rows = 10000
+	counts := map[string]int{}
+	for i := 0; i < rows; i++ {
+		counts[generator.generateRow(int64(i))[0]]++
+	}
+
+	expected := map[string]float64{"active": 0.7, "inactive": 0.2, "pending": 0.1}
+	if len(counts) != len(expected) {
+		t.Fatalf("Expected only the options %v, got %v", expected, counts)
+	}
+
+	for option, expectedRate := range expected {
+		rate := float64(counts[option]) / rows
+		if math.Abs(rate-expectedRate) > 0.02 {
+			t.Errorf("Expected %s about %.0f%% of the time, got %.1f%%", option, expectedRate*100, rate*100)
+		}
+	}
+}
+
+func TestGenerateRows(t *testing.T) {
+	fieldSlice := []string{"name", "age"}
+
+	for _, workers := range []int{1, 3, 8} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			done := make(chan struct{})
+			defer close(done)
+
+			count := 0
+			for row := range generateRows(Options{Rows: 100, Workers: workers}, mustParseColumns(t, fieldSlice...), nil, done) {
+				if len(row) != len(fieldSlice) {
+					t.Errorf("Expected %d values, got %d", len(fieldSlice), len(row))
+				}
+				count++
+			}
+
+			if count != 100 {
+				t.Errorf("Expected 100 rows, got %d", count)
+			}
+		})
+	}
+}
+
+func TestGenerateRows_StopsWhenDone(t *testing.T) {
+	done := make(chan struct{})
+	rows := generateRows(Options{Rows: 1000000, Workers: 4}, mustParseColumns(t, "name"), nil, done)
+
+	<-rows
+	close(done)
+
+	// The channel is closed once the workers stop, rather than after every row is generated.
+	count := 0
+	for range rows {
+		count++
+	}
+
+	if count >= 1000000-1 {
+		t.Errorf("Expected generation to stop early, got %d more rows", count)
+	}
+}
+
+func TestGenerate_ErrorCases(t *testing.T) {
+	opts := Options{
+		Rows:      1,
+		Fields:    "email",
+		Filename:  "output.csv",
+		OutputDir: "output",
+		Delimiter: ",",
+		Seed:      1,
+	}
+
+	tests := []struct {
+		name          string
+		args          []string
+		fileHandler   FileHandler
+		fileWriter    FileWriter
+		dataGenerator DataGenerator
+		expectedError string
+	}{
+		{
+			name:          "Generate csv data fails",
+			fileHandler:   &MockFileHandler{},
+			fileWriter:    &MockFileWriter{},
+			dataGenerator: &MockDataGenerator{ShouldFail: true},
+			expectedError: "Failed to generate CSV data: generateCsvData failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := generate(context.Background(), tt.fileHandler, tt.fileWriter, tt.dataGenerator, opts)
+
+			if err == nil || err.Error() != tt.expectedError {
+				t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestGenerate_SuccessCases(t *testing.T) {
+	opts := Options{
+		Rows:      1,
+		Fields:    "email",
+		Filename:  "output.csv",
+		OutputDir: "output",
+		Delimiter: ",",
+		Seed:      1,
+	}
+
+	tests := []struct {
+		name          string
+		args          []string
+		fileHandler   FileHandler
+		fileWriter    FileWriter
+		dataGenerator DataGenerator
+		expectedOut   string
+	}{
+		{
+			name:          "Generate csv data success",
+			args:          []string{"-rows", "1"},
+			fileHandler:   &MockFileHandler{},
+			fileWriter:    &MockFileWriter{},
+			dataGenerator: &MockDataGenerator{ShouldFail: false},
+			expectedOut:   "CSV file successfully generated at output/output.csv.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := opts
+			opts.Log = &buf
+
+			if err := generate(context.Background(), tt.fileHandler, tt.fileWriter, tt.dataGenerator, opts); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			actualOut := buf.String()
+			lines := strings.Split(actualOut, "\n")
+			output := lines[5]
+
+			if output != tt.expectedOut {
+				t.Errorf("\nExpected output:\n%s\nGot:\n%s", tt.expectedOut, output)
+			}
+		})
+	}
+}
+
+// droppingFieldWriter writes the second row, the third record after the header, without
+// its last field, like a generator bug that leaves a row short.
+type droppingFieldWriter struct {
+	records *int
+}
+
+func (d droppingFieldWriter) Write(record []string, writer RecordWriter) error {
+	*d.records++
+	if *d.records == 3 {
+		record = record[:len(record)-1]
+	}
+
+	return writer.Write(record)
+}
+
+// failingAfterWriter writes the given number of records and fails to write any after
+// them, like a disk filling up partway through a run.
+type failingAfterWriter struct {
+	records int
+	written *int
+}
+
+func (f failingAfterWriter) Write(record []string, writer RecordWriter) error {
+	if *f.written == f.records {
+		return fmt.Errorf("Write failed")
+	}
+	*f.written++
+
+	return writer.Write(record)
+}
+
+func TestGenerateCsvData_PartialOutput(t *testing.T) {
+	tests := []struct {
+		name         string
+		keepPartial  bool
+		appendMode   bool
+		expectedFile bool
+	}{
+		{name: "Partial file is removed", expectedFile: false},
+		{name: "Partial file is kept with KeepPartial", keepPartial: true, expectedFile: true},
+		{name: "File being appended to is kept", appendMode: true, expectedFile: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{Rows: 10, Fields: "name", Filename: "output.csv", OutputDir: t.TempDir(), Delimiter: ",", Workers: 1, Overwrite: true, KeepPartial: tt.keepPartial, Append: tt.appendMode}
+			writer := failingAfterWriter{records: 5, written: new(int)}
+
+			err := (CSVDataGenerator{}).generateCsvData(opts, OSFileHandler{}, writer)
+			if expectedError := "failed to write row: Write failed"; err == nil || err.Error() != expectedError {
+				t.Errorf("Expected error: %v\nGot: %v", expectedError, err)
+			}
+
+			_, statErr := os.Stat(filepath.Join(opts.OutputDir, opts.Filename))
+			if exists := statErr == nil; exists != tt.expectedFile {
+				t.Errorf("Expected the file to exist: %v, got: %v", tt.expectedFile, exists)
+			}
+
+			if _, err := os.Stat(filepath.Join(opts.OutputDir, opts.Filename+tempSuffix)); err == nil {
+				t.Errorf("Expected no temporary file to be left behind")
+			}
+		})
+	}
+
+	t.Run("Cancelled generation is kept", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		opts := Options{Rows: 10, Fields: "name", Filename: "output.csv", OutputDir: t.TempDir(), Delimiter: ",", Workers: 1, Overwrite: true}
+		if err := (CSVDataGenerator{}).generateCsvDataContext(ctx, opts, OSFileHandler{}, CSVFileWriter{}); err == nil {
+			t.Fatalf("Expected the generation to be cancelled")
+		}
+
+		if _, err := os.Stat(filepath.Join(opts.OutputDir, opts.Filename)); err != nil {
+			t.Errorf("Expected the rows written before cancelling to be kept, got: %v", err)
+		}
+	})
+}
+
+// outputCheckingWriter records whether the output file existed while records were being
+// written.
+type outputCheckingWriter struct {
+	path        string
+	sawOutput   *bool
+	sawTempFile *bool
+}
+
+func (o outputCheckingWriter) Write(record []string, writer RecordWriter) error {
+	if _, err := os.Stat(o.path); err == nil {
+		*o.sawOutput = true
+	}
+	if _, err := os.Stat(o.path + tempSuffix); err == nil {
+		*o.sawTempFile = true
+	}
+
+	return writer.Write(record)
+}
+
+func TestGenerateCsvData_AtomicRename(t *testing.T) {
+	opts := Options{Rows: 10, Fields: "name", Filename: "output.csv", OutputDir: t.TempDir(), Delimiter: ",", Workers: 1, Overwrite: true}
+	path := filepath.Join(opts.OutputDir, opts.Filename)
+	writer := outputCheckingWriter{path: path, sawOutput: new(bool), sawTempFile: new(bool)}
+
+	if err := (CSVDataGenerator{}).generateCsvData(opts, OSFileHandler{}, writer); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if *writer.sawOutput || !*writer.sawTempFile {
+		t.Errorf("Expected rows to be written to the temporary file before the output file exists")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected the output file to exist, got: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 11 {
+		t.Errorf("Expected the header and 10 rows, got %d lines", lines)
+	}
+
+	if _, err := os.Stat(path + tempSuffix); err == nil {
+		t.Errorf("Expected the temporary file to be renamed")
+	}
+}
+
+func TestGenerate_Verify(t *testing.T) {
+	tests := []struct {
+		name          string
+		gzip          bool
+		fileWriter    func() FileWriter
+		expectedError string
+	}{
+		{
+			name:       "Valid output",
+			fileWriter: func() FileWriter { return CSVFileWriter{} },
+		},
+		{
+			name:       "Valid gzip output",
+			gzip:       true,
+			fileWriter: func() FileWriter { return CSVFileWriter{} },
+		},
+		{
+			name:          "Row with a missing field",
+			fileWriter:    func() FileWriter { return droppingFieldWriter{records: new(int)} },
+			expectedError: "Failed to verify CSV data: record on line 3: wrong number of fields",
+		},
+		{
+			name:          "Gzip row with a missing field",
+			gzip:          true,
+			fileWriter:    func() FileWriter { return droppingFieldWriter{records: new(int)} },
+			expectedError: "Failed to verify CSV data: record on line 3: wrong number of fields",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := Options{Rows: 5, Fields: "name,sentence", Filename: "output.csv", OutputDir: t.TempDir(), Delimiter: ",", Workers: 1, Overwrite: true, Seed: 1, Gzip: tt.gzip, Verify: true, Log: &buf}
+
+			err := generate(context.Background(), OSFileHandler{}, tt.fileWriter(), CSVDataGenerator{}, opts)
+			if tt.expectedError != "" {
+				if err == nil || err.Error() != tt.expectedError {
+					t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if !strings.Contains(buf.String(), "Verified 6 records.") {
+				t.Errorf("Expected the verified records to be reported, got:\n%s", buf.String())
+			}
+		})
+	}
+}
+
+func TestGenerateCsvData_ErrorCases(t *testing.T) {
+	opts := Options{
+		Rows:      1,
+		Fields:    "email",
+		Filename:  "output.csv",
+		OutputDir: "output",
+		Delimiter: ",",
+		Workers:   1,
+	}
+	dataGenerator := CSVDataGenerator{}
+
+	tests := []struct {
+		name          string
+		args          []string
+		fileHandler   FileHandler
+		fileWriter    FileWriter
+		appendMode    bool
+		expectedError string
+	}{
+		{
+			name:          "FileHandler.MkDirAll fails",
+			fileHandler:   &MockFileHandler{ShouldFailMkDirAll: true},
+			fileWriter:    &MockFileWriter{ShouldFail: false},
+			expectedError: "failed to create directory: MkDirAll failed",
+		},
+		{
+			name:          "FilerHandler.Create fails",
+			fileHandler:   &MockFileHandler{ShouldFailCreate: true},
+			fileWriter:    &MockFileWriter{ShouldFail: false},
+			expectedError: "Create failed",
+		},
+		{
+			name:          "FileWriter.Write fails",
+			fileHandler:   &MockFileHandler{},
+			fileWriter:    &MockFileWriter{ShouldFail: true},
+			expectedError: "failed to write header row: Write failed",
+		},
+		{
+			name:          "Flushing to the file fails",
+			fileHandler:   &MockFileHandler{ShouldFailWrite: true},
+			fileWriter:    &CSVFileWriter{},
+			expectedError: "failed to flush rows: file write failed",
+		},
+		{
+			name:          "File exists and overwriting is disabled",
+			fileHandler:   &MockFileHandler{FileExists: true},
+			fileWriter:    &MockFileWriter{},
+			expectedError: "output/output.csv already exists and overwriting is disabled",
+		},
+		{
+			name:          "FileHandler.Exists fails",
+			fileHandler:   &MockFileHandler{ShouldFailExists: true},
+			fileWriter:    &MockFileWriter{},
+			expectedError: "failed to check for an existing file: Exists failed",
+		},
+		{
+			name:          "FileHandler.OpenAppend fails",
+			appendMode:    true,
+			fileHandler:   &MockFileHandler{ShouldFailAppend: true},
+			fileWriter:    &MockFileWriter{},
+			expectedError: "OpenAppend failed",
+		},
+		{
+			name:          "Closing the output fails",
+			fileHandler:   &MockFileHandler{ShouldFailClose: true},
+			fileWriter:    &CSVFileWriter{},
+			expectedError: "failed to close output: Close failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := opts
+			opts.Append = tt.appendMode
+			err := dataGenerator.generateCsvData(opts, tt.fileHandler, tt.fileWriter)
+
+			if err == nil || err.Error() != tt.expectedError {
+				t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestGenerateCsvData_Retries(t *testing.T) {
+	origBackoff := retryBackoff
+	retryBackoff = 0
+	defer func() {
+		retryBackoff = origBackoff
+	}()
+
+	tests := []struct {
+		name          string
+		retries       int
+		appendMode    bool
+		fileHandler   *MockFlakyFileHandler
+		expectedCalls int
+		expectedError string
+	}{
+		{
+			name:          "Create succeeds after retries",
+			retries:       3,
+			fileHandler:   &MockFlakyFileHandler{MkDirAllFailures: 1, CreateFailures: 3},
+			expectedCalls: 6,
+		},
+		{
+			name:          "OpenAppend succeeds after retries",
+			retries:       2,
+			appendMode:    true,
+			fileHandler:   &MockFlakyFileHandler{AppendFailures: 2},
+			expectedCalls: 4,
+		},
+		{
+			name:          "Create fails every attempt",
+			retries:       2,
+			fileHandler:   &MockFlakyFileHandler{CreateFailures: 3},
+			expectedCalls: 4,
+			expectedError: "Create failed",
+		},
+		{
+			name:          "MkDirAll fails every attempt",
+			retries:       1,
+			fileHandler:   &MockFlakyFileHandler{MkDirAllFailures: 2},
+			expectedCalls: 2,
+			expectedError: "failed to create directory: MkDirAll failed",
+		},
+		{
+			name:          "No retries",
+			fileHandler:   &MockFlakyFileHandler{CreateFailures: 1},
+			expectedCalls: 2,
+			expectedError: "Create failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{
+				Rows:      1,
+				Fields:    "name",
+				Filename:  "output.csv",
+				OutputDir: "output",
+				Delimiter: ",",
+				Overwrite: true,
+				Workers:   1,
+				Retries:   tt.retries,
+				Append:    tt.appendMode,
+			}
+
+			err := (CSVDataGenerator{}).generateCsvData(opts, tt.fileHandler, CSVFileWriter{})
+			if tt.expectedError == "" && err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+			if tt.expectedError != "" && (err == nil || err.Error() != tt.expectedError) {
+				t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
+			}
+
+			if tt.fileHandler.Calls != tt.expectedCalls {
+				t.Errorf("Expected %d calls to the file handler, got %d", tt.expectedCalls, tt.fileHandler.Calls)
+			}
+		})
+	}
+}
+
+func TestGenerateCsvData_DirPerm(t *testing.T) {
+	tests := []struct {
+		name         string
+		dirPerm      string
+		expectedPerm os.FileMode
+	}{
+		{name: "Default permissions", dirPerm: "", expectedPerm: 0o755},
+		{name: "Custom permissions", dirPerm: "0700", expectedPerm: 0o700},
+		{name: "Without a leading zero", dirPerm: "750", expectedPerm: 0o750},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{Rows: 1, Fields: "name", Filename: "output.csv", OutputDir: "output", Delimiter: ",", Overwrite: true, Workers: 1, DirPerm: tt.dirPerm}
+			fileHandler := &MockFlakyFileHandler{}
+
+			if err := (CSVDataGenerator{}).generateCsvData(opts, fileHandler, CSVFileWriter{}); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if fileHandler.DirPerm != tt.expectedPerm {
+				t.Errorf("Expected the directory to be created with permissions %o, got %o", tt.expectedPerm, fileHandler.DirPerm)
+			}
+		})
+	}
+
+	opts := Options{Rows: 1, Fields: "name", Filename: "output.csv", OutputDir: filepath.Join(t.TempDir(), "private"), Delimiter: ",", Overwrite: true, Workers: 1, DirPerm: "0700"}
+	if err := (CSVDataGenerator{}).generateCsvData(opts, OSFileHandler{}, CSVFileWriter{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	info, err := os.Stat(opts.OutputDir)
+	if err != nil {
+		t.Fatalf("Failed to stat the output directory: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o700 {
+		t.Errorf("Expected the output directory to have permissions 700, got %o", perm)
+	}
+}
+
+func TestGenerateCsvData_Unique(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			opts := Options{
+				Rows:      50,
+				Fields:    "int(1,50),name",
+				Filename:  "unique.csv",
+				OutputDir: t.TempDir(),
+				Delimiter: ",",
+				Overwrite: true,
+				Workers:   workers,
+				Unique:    "int(1,50)",
+				Seed:      1,
+			}
+
+			// generateUnique returns the generated records, excluding the header row.
+			generateUnique := func() [][]string {
+				if err := (CSVDataGenerator{}).generateCsvData(opts, OSFileHandler{}, CSVFileWriter{}); err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+
+				outputFile, err := os.Open(filepath.Join(opts.OutputDir, opts.Filename))
+				if err != nil {
+					t.Fatalf("Failed to open output file: %v", err)
+				}
+				defer outputFile.Close()
+
+				records, err := csv.NewReader(outputFile).ReadAll()
+				if err != nil {
+					t.Fatalf("Failed to read CSV file: %v", err)
+				}
+
+				return records[1:]
+			}
+
+			records := generateUnique()
+			if len(records) != 50 {
+				t.Fatalf("Expected 50 rows, got %d", len(records))
+			}
+
+			// Every one of the 50 possible values is used exactly once.
+			seen := map[string]bool{}
+			for _, record := range records {
+				if seen[record[0]] {
+					t.Errorf("Duplicate unique value %q", record[0])
+				}
+				seen[record[0]] = true
+			}
+
+			if !slices.EqualFunc(generateUnique(), records, slices.Equal[[]string]) {
+				t.Errorf("Expected the same unique rows for the same seed")
+			}
+		})
+	}
+}
+
+func TestGenerateCsvData_UniqueErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		fields        string
+		unique        string
+		rows          int64
+		expectedError string
+	}{
+		{
+			name:          "Not enough possible values",
+			fields:        "age",
+			unique:        "age",
+			rows:          200,
+			expectedError: "unable to generate a unique age after 100 attempts, 82 values have been used",
+		},
+		{
+			name:          "Unique field isn't selected",
+			fields:        "name",
+			unique:        "age",
+			rows:          1,
+			expectedError: "unique field \"age\" is not one of the selected fields",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{
+				Rows:      tt.rows,
+				Fields:    tt.fields,
+				Filename:  "output.csv",
+				OutputDir: "output",
+				Delimiter: ",",
+				Workers:   1,
+				Unique:    tt.unique,
+				Seed:      1,
+			}
+
+			err := (CSVDataGenerator{}).generateCsvData(opts, &MockFileHandler{}, CSVFileWriter{})
+			if err == nil || err.Error() != tt.expectedError {
+				t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+// cancellingFileWriter cancels a context once a number of rows, excluding the header
+// row, have been written.
+type cancellingFileWriter struct {
+	CSVFileWriter
+	cancel      context.CancelFunc
+	cancelAfter int
+	writes      int
+}
+
+func (w *cancellingFileWriter) Write(record []string, writer RecordWriter) error {
+	if w.writes == w.cancelAfter+1 {
+		w.cancel()
+	}
+	w.writes++
+
+	return w.CSVFileWriter.Write(record, writer)
+}
+
+func TestGenerateCsvDataContext_Cancelled(t *testing.T) {
+	tests := []struct {
+		name          string
+		cancelAfter   int
+		expectedRows  int
+		expectedError string
+	}{
+		{
+			name:          "Cancelled before the first row",
+			cancelAfter:   -1,
+			expectedRows:  0,
+			expectedError: "generation cancelled after 0 rows: context canceled",
+		},
+		{
+			name:          "Cancelled mid-run",
+			cancelAfter:   1500,
+			expectedRows:  2000,
+			expectedError: "generation cancelled after 2000 rows: context canceled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{
+				Rows:      10000,
+				Fields:    "id,name",
+				Filename:  "cancelled.csv",
+				OutputDir: t.TempDir(),
+				Delimiter: ",",
+				Workers:   2,
+				Seed:      1,
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelAfter < 0 {
+				cancel()
+			}
+
+			fileWriter := &cancellingFileWriter{cancel: cancel, cancelAfter: tt.cancelAfter}
+			err := (CSVDataGenerator{}).generateCsvDataContext(ctx, opts, OSFileHandler{}, fileWriter)
+			if err == nil || err.Error() != tt.expectedError {
+				t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
+			}
+
+			outputFile, err := os.Open(filepath.Join(opts.OutputDir, opts.Filename))
+			if err != nil {
+				t.Fatalf("Failed to open output file: %v", err)
+			}
+			defer outputFile.Close()
+
+			records, err := csv.NewReader(outputFile).ReadAll()
+			if err != nil {
+				t.Fatalf("Failed to read CSV file: %v", err)
+			}
+
+			if len(records) != tt.expectedRows+1 {
+				t.Fatalf("Expected %d rows and a header row, got %d records", tt.expectedRows, len(records))
+			}
+
+			// The rows written before the cancellation are the same rows an uncancelled
+			// run starts with.
+			for i, record := range records[1:] {
+				if record[0] != strconv.Itoa(i+1) {
+					t.Fatalf("Expected row %d to have id %d, got: %v", i, i+1, record)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONGenerateCsvData_ErrorCases(t *testing.T) {
+	opts := Options{
+		Rows:      1,
+		Fields:    "email",
+		Filename:  "output.json",
+		OutputDir: "output",
+		Workers:   1,
+	}
+	dataGenerator := JSONDataGenerator{}
+
+	tests := []struct {
+		name          string
+		fileHandler   FileHandler
+		expectedError string
+	}{
+		{
+			name:          "FileHandler.MkDirAll fails",
+			fileHandler:   &MockFileHandler{ShouldFailMkDirAll: true},
+			expectedError: "failed to create directory: MkDirAll failed",
+		},
+		{
+			name:          "FilerHandler.Create fails",
+			fileHandler:   &MockFileHandler{ShouldFailCreate: true},
+			expectedError: "Create failed",
+		},
+		{
+			name:          "Flushing to the file fails",
+			fileHandler:   &MockFileHandler{ShouldFailWrite: true},
+			expectedError: "failed to flush rows: file write failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := dataGenerator.generateCsvData(opts, tt.fileHandler, &MockFileWriter{})
+
+			if err == nil || err.Error() != tt.expectedError {
+				t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestGenerateCsvData_SuccessCases(t *testing.T) {
+	opts := Options{
+		Rows:      1,
+		Fields:    "email",
+		Filename:  "output.csv",
+		OutputDir: "output",
+		Delimiter: ",",
+		Workers:   1,
+	}
+	dataGenerator := CSVDataGenerator{}
+
+	tests := []struct {
+		name        string
+		args        []string
+		fileHandler FileHandler
+		fileWriter  FileWriter
+	}{
+		{
+			name:        "Successfully write to csv data file",
+			fileHandler: &MockFileHandler{},
+			fileWriter:  &MockFileWriter{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := dataGenerator.generateCsvData(opts, tt.fileHandler, tt.fileWriter)
+
+			if err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestReadFieldsFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		expected string
+	}{
+		{name: "Comma separated", contents: "name,age,email", expected: "name,age,email"},
+		{name: "Newline separated", contents: "name\nage\nemail\n", expected: "name,age,email"},
+		{name: "CRLF line endings", contents: "name\r\nage\r\n", expected: "name,age"},
+		{name: "Mixed with blank lines", contents: "name, age\n\n  int(1,10) ,\nemail\n", expected: "name,age,int(1,10),email"},
+		{name: "Empty", contents: "\n", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "fields.txt")
+			if err := os.WriteFile(path, []byte(tt.contents), 0666); err != nil {
+				t.Fatalf("Failed to write fields file: %v", err)
+			}
+
+			fields, err := ReadFieldsFile(path)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if fields != tt.expected {
+				t.Errorf("Expected fields %q, got %q", tt.expected, fields)
+			}
+		})
+	}
+}
+
+func TestValidateFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		valid    bool
+	}{
+		{filename: "output.csv", valid: true},
+		{filename: "data..csv", valid: true},
+		{filename: ".hidden.csv", valid: true},
+		{filename: "-", valid: true},
+		{filename: "..", valid: false},
+		{filename: ".", valid: false},
+		{filename: "../output.csv", valid: false},
+		{filename: "sub/output.csv", valid: false},
+		{filename: "/etc/passwd", valid: false},
+		{filename: `..\output.csv`, valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			err := validateFilename(tt.filename)
+			if tt.valid && err != nil {
+				t.Errorf("Expected %q to be valid, got: %v", tt.filename, err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("Expected %q to be invalid", tt.filename)
+			}
+		})
+	}
+}
+
+func TestValidFieldNames(t *testing.T) {
+	names := validFieldNames()
+
+	if len(names) != len(validFields) {
+		t.Errorf("Expected %d field names, got %d", len(validFields), len(names))
+	}
+
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("Expected field names to be sorted, got: %v", names)
+	}
+
+	for _, name := range names {
+		if !validFields[name] {
+			t.Errorf("Unexpected field name: %s", name)
+		}
+	}
+}
+
+func TestGenerateBaseFields_AddressConsistency(t *testing.T) {
+	faker := gofakeit.New(1)
+
+	for i := 0; i < 100; i++ {
+		rc := rowContext{faker: faker, fields: generateBaseFields(faker, baseFieldOptions{})}
+		address := generators["address"](rc)
+
+		for _, field := range []string{"city", "state", "zip"} {
+			value := generators[field](rc)
+			if !strings.Contains(address, value) {
+				t.Errorf("Expected address %q to contain %s %q", address, field, value)
+			}
+		}
+	}
+}
+
+func TestGenerateBaseFields_GenderConsistency(t *testing.T) {
+	faker := gofakeit.New(1)
+
+	for i := 0; i < 100; i++ {
+		fields := generateBaseFields(faker, baseFieldOptions{withGender: true})
+
+		names, ok := firstNamesByGender[fields.Gender]
+		if !ok {
+			t.Fatalf("Unexpected gender %q", fields.Gender)
+		}
+
+		if !slices.Contains(names, fields.FirstName) {
+			t.Errorf("Expected first name %q to be a %s name", fields.FirstName, fields.Gender)
+		}
+
+		if !strings.HasPrefix(fields.Name, fields.FirstName+" ") {
+			t.Errorf("Expected name %q to start with first name %q", fields.Name, fields.FirstName)
+		}
+	}
+}
+
+func TestGenerateBaseFields_JobConsistency(t *testing.T) {
+	faker := gofakeit.New(1)
+
+	for i := 0; i < 100; i++ {
+		fields := generateBaseFields(faker, baseFieldOptions{withCompany: true, withJob: true})
+
+		if fields.Job == nil || fields.Company != fields.Job.Company {
+			t.Fatalf("Expected the company to be the job's company, got %q and %+v", fields.Company, fields.Job)
+		}
+
+		if !strings.HasSuffix(fields.Email, "@"+companyDomain(fields.Company)) {
+			t.Errorf("Expected email %q to use the domain of %q", fields.Email, fields.Company)
+		}
+	}
+}
+
+func TestCompanyDomain(t *testing.T) {
+	tests := []struct {
+		company  string
+		expected string
+	}{
+		{company: "Acme", expected: "acme.com"},
+		{company: "Acme Corp, Inc.", expected: "acme-corp-inc.com"},
+		{company: "T. Rowe Price", expected: "t-rowe-price.com"},
+		{company: "AT&T", expected: "at-t.com"},
+		{company: "3M", expected: "3m.com"},
+	}
+
+	for _, tt := range tests {
+		if actual := companyDomain(tt.company); actual != tt.expected {
+			t.Errorf("Expected domain %q for %q, got %q", tt.expected, tt.company, actual)
+		}
+	}
+}
+
+func TestGenerateBaseFields_UsernameMatchesEmail(t *testing.T) {
+	faker := gofakeit.New(1)
+
+	for i := 0; i < 100; i++ {
+		fields := generateBaseFields(faker, baseFieldOptions{})
+
+		localPart, _, _ := strings.Cut(fields.Email, "@")
+		if fields.Username != localPart {
+			t.Errorf("Expected username %q to match the local part of email %q", fields.Username, fields.Email)
+		}
+	}
+}
+
+func BenchmarkGenerateCsvData(b *testing.B) {
+	opts := Options{
+		Rows:      10000,
+		Fields:    "name,age,email,city",
+		Filename:  "bench.csv",
+		OutputDir: b.TempDir(),
+		Delimiter: ",",
+		Workers:   1,
+		Overwrite: true,
+	}
+	dataGenerator := CSVDataGenerator{}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := dataGenerator.generateCsvData(opts, OSFileHandler{}, CSVFileWriter{}); err != nil {
+			b.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+}
+
+// BenchmarkGenerateRow compares allocating a new slice for every row with generating each
+// row into the slice of the previous one, as the rows of a run are recycled once written.
+func BenchmarkGenerateRow(b *testing.B) {
+	generator := newRowGenerator(Options{Seed: 1}, mustParseColumns(b, "id,name,age,email,city,uuid"), 0)
+
+	b.Run("New row", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			generator.generateRow(int64(i))
+		}
+	})
+
+	b.Run("Reused row", func(b *testing.B) {
+		b.ReportAllocs()
+		var row []string
+		for i := 0; i < b.N; i++ {
+			row = generator.appendRow(row[:0], int64(i))
+		}
+	})
+}
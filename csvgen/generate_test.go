@@ -0,0 +1,186 @@
+package csvgen_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-test-csv-generator/csvgen"
+)
+
+func ExampleGenerate() {
+	opts := csvgen.DefaultOptions()
+	opts.Rows = 2
+	opts.Fields = "id,name,email"
+	opts.Filename = "-"
+	opts.Seed = 1
+
+	if err := csvgen.Generate(opts); err != nil {
+		panic(err)
+	}
+	// Output:
+	// id,name,email
+	// 1,Zion Brakus,zion.brakus@productparadigms.biz
+	// 2,Federico Prosacco,federico.prosacco@regionalintegrate.net
+}
+
+func ExamplePreview() {
+	opts := csvgen.DefaultOptions()
+	opts.Fields = "name,city"
+	opts.Seed = 1
+
+	if err := csvgen.Preview(os.Stdout, opts); err != nil {
+		panic(err)
+	}
+	// Output:
+	// name,city
+	// Zion Brakus,Omaha
+}
+
+func TestGenerate(t *testing.T) {
+	opts := csvgen.DefaultOptions()
+	opts.Rows = 2
+	opts.OutputDir = t.TempDir()
+	opts.Filename = "people.csv"
+	opts.Seed = 1
+
+	if err := csvgen.Generate(opts); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(opts.OutputDir, opts.Filename))
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+
+	expectedData := "name,age\nZion Brakus,59\nFederico Prosacco,66\n"
+	if string(data) != expectedData {
+		t.Errorf("\nExpected file data:\n%s\nGot:\n%s", expectedData, data)
+	}
+}
+
+func TestGenerateToWriter(t *testing.T) {
+	tests := []struct {
+		name         string
+		fields       string
+		format       string
+		expectedData string
+	}{
+		{
+			name:         "CSV",
+			fields:       "id,name",
+			format:       "csv",
+			expectedData: "id,name\n1,Zion Brakus\n2,Federico Prosacco\n",
+		},
+		{
+			name:         "JSON",
+			fields:       "id,name",
+			format:       "json",
+			expectedData: "{\"id\":\"1\",\"name\":\"Zion Brakus\"}\n{\"id\":\"2\",\"name\":\"Federico Prosacco\"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := csvgen.DefaultOptions()
+			opts.Rows = 2
+			opts.Fields = tt.fields
+			opts.Format = tt.format
+			opts.Seed = 1
+
+			var buf bytes.Buffer
+			if err := csvgen.GenerateToWriter(&buf, opts); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if buf.String() != tt.expectedData {
+				t.Errorf("\nExpected data:\n%s\nGot:\n%s", tt.expectedData, buf.String())
+			}
+		})
+	}
+}
+
+func TestGenerateToWriter_Gzip(t *testing.T) {
+	opts := csvgen.DefaultOptions()
+	opts.Rows = 2
+	opts.Fields = "id"
+	opts.Gzip = true
+	opts.Seed = 1
+
+	var buf bytes.Buffer
+	if err := csvgen.GenerateToWriter(&buf, opts); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	reader, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("Expected gzip compressed data, got: %v", err)
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to decompress data: %v", err)
+	}
+
+	expectedData := "id\n1\n2\n"
+	if string(data) != expectedData {
+		t.Errorf("\nExpected data:\n%s\nGot:\n%s", expectedData, data)
+	}
+}
+
+func TestGenerateToWriter_InvalidOptions(t *testing.T) {
+	opts := csvgen.DefaultOptions()
+	opts.Fields = "name,foo"
+
+	var buf bytes.Buffer
+	err := csvgen.GenerateToWriter(&buf, opts)
+
+	expectedError := "Unable to generate CSV data. Invalid fields selected: foo. Valid fields are: " + strings.Join(csvgen.SupportedFields(), ", ")
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error: %v\nGot: %v", expectedError, err)
+	}
+
+	if buf.Len() != 0 {
+		t.Errorf("Expected nothing to be written, got: %q", buf.String())
+	}
+}
+
+func TestGenerate_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		rows          int64
+		fields        string
+		expectedError string
+	}{
+		{
+			name:          "Invalid number of rows",
+			rows:          0,
+			fields:        "name",
+			expectedError: "Invalid options: invalid number of rows: 0",
+		},
+		{
+			name:          "Invalid field",
+			rows:          1,
+			fields:        "name,foo",
+			expectedError: "Unable to generate CSV data. Invalid fields selected: foo. Valid fields are: " + strings.Join(csvgen.SupportedFields(), ", "),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := csvgen.DefaultOptions()
+			opts.Rows = tt.rows
+			opts.Fields = tt.fields
+			opts.OutputDir = t.TempDir()
+
+			err := csvgen.Generate(opts)
+			if err == nil || err.Error() != tt.expectedError {
+				t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+//go:build parquet
+
+package csvgen
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Parquet output pulls in a large dependency, so it's only built with -tags parquet.
+func init() {
+	dataGenerators["parquet"] = ParquetDataGenerator{}
+	tableExtensions["parquet"] = ".parquet"
+}
+
+// parquetIntFields are the fields written as parquet integers, every other column is
+// written as a string.
+var parquetIntFields = map[string]bool{
+	"age": true,
+}
+
+// ParquetDataGenerator writes a parquet file with a column for each selected field. Empty
+// values, e.g. from opts.NullRate, are written as nulls. The csvWriter is unused.
+type ParquetDataGenerator struct{}
+
+func (d ParquetDataGenerator) generateCsvDataContext(ctx context.Context, opts Options, fileHandler FileHandler, csvWriter FileWriter) (err error) {
+	// A parquet file ends with a footer describing all of its rows.
+	if opts.Append {
+		return fmt.Errorf("parquet files can't be appended to")
+	}
+
+	if _, _, err := parseOutputColumns(opts); err != nil {
+		return err
+	}
+
+	file, hasContent, err := openOutput(opts, fileHandler)
+	if err != nil {
+		return err
+	}
+	defer finishOutput(opts, fileHandler, &err)
+	defer closeOutput(file, &err)
+
+	return d.writeData(ctx, file, hasContent, opts, csvWriter)
+}
+
+// writeData writes a parquet file of the generated rows to w. The schema is part of the
+// file, so w must not already hold data.
+func (d ParquetDataGenerator) writeData(ctx context.Context, w io.Writer, hasContent bool, opts Options, csvWriter FileWriter) (err error) {
+	if hasContent {
+		return fmt.Errorf("parquet files can't be appended to")
+	}
+
+	// Rows are buffered into row groups, so the size of the output isn't known as they're
+	// written.
+	if opts.MaxBytes > 0 {
+		return fmt.Errorf("parquet output can't be limited to a number of bytes")
+	}
+
+	columns, unique, err := parseOutputColumns(opts)
+	if err != nil {
+		return err
+	}
+
+	schema, leaves, err := newParquetSchema(columns)
+	if err != nil {
+		return err
+	}
+
+	buffered := bufio.NewWriterSize(w, writeBufferSize)
+	writer := parquet.NewWriter(buffered, schema)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	var cancelErr error
+	values := make(parquet.Row, len(columns))
+	progress := newProgressReporter(opts)
+	recycle := newRowRecycler(opts.Workers)
+	for row := range generateRows(opts, columns, recycle, done) {
+		if cancelErr = checkCancelled(ctx, progress.written); cancelErr != nil {
+			break
+		}
+
+		if row, err = unique.enforce(row); err != nil {
+			return err
+		}
+
+		if err := parquetRow(values, row, columns, leaves); err != nil {
+			return err
+		}
+
+		if _, err := writer.WriteRows([]parquet.Row{values}); err != nil {
+			return fmt.Errorf("failed to write row: %v", err)
+		}
+		progress.rowWritten()
+		recycle.add(row)
+	}
+
+	// Closing the writer flushes the last row group and writes the footer.
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to flush rows: %v", err)
+	}
+
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("failed to flush rows: %v", err)
+	}
+
+	return cancelErr
+}
+
+// newParquetSchema returns the schema of a parquet file with a nullable column for each of
+// columns, named by its header, and the index of each column in the rows of the file.
+// Parquet orders the columns of a group by name, so the indexes differ from the order of
+// columns.
+func newParquetSchema(columns []column) (*parquet.Schema, []int, error) {
+	names := columnNames(columns)
+	group := parquet.Group{}
+	for i, name := range names {
+		if _, ok := group[name]; ok {
+			return nil, nil, fmt.Errorf("parquet columns must have different names, %s is selected more than once", name)
+		}
+
+		// The type comes from the field, as parquetRow writes its values, whatever case
+		// the header keeps.
+		if parquetIntFields[columns[i].name] {
+			group[name] = parquet.Optional(parquet.Int(64))
+		} else {
+			group[name] = parquet.Optional(parquet.String())
+		}
+	}
+
+	schema := parquet.NewSchema("row", group)
+	leaves := make([]int, len(names))
+	for i, name := range names {
+		leaf, _ := schema.Lookup(name)
+		leaves[i] = leaf.ColumnIndex
+	}
+
+	return schema, leaves, nil
+}
+
+// parquetRow fills values with the parquet values of row, in the column order of the
+// schema returned by newParquetSchema.
+func parquetRow(values parquet.Row, row []string, columns []column, leaves []int) error {
+	for i, value := range row {
+		index := leaves[i]
+		if value == "" {
+			values[index] = parquet.NullValue().Level(0, 0, index)
+			continue
+		}
+
+		if !parquetIntFields[columns[i].name] {
+			values[index] = parquet.ByteArrayValue([]byte(value)).Level(0, 1, index)
+			continue
+		}
+
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be an integer in parquet output, got %q", columns[i].name, value)
+		}
+		values[index] = parquet.Int64Value(n).Level(0, 1, index)
+	}
+
+	return nil
+}
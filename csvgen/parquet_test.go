@@ -0,0 +1,193 @@
+//go:build parquet
+
+package csvgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// readParquet returns the values of the named columns of each row of a parquet file, with
+// nulls read back as empty values.
+func readParquet(t *testing.T, data []byte, names []string) [][]string {
+	t.Helper()
+
+	reader := parquet.NewReader(bytes.NewReader(data))
+	defer reader.Close()
+
+	var leaves []int
+	for _, name := range names {
+		leaf, ok := reader.Schema().Lookup(name)
+		if !ok {
+			t.Fatalf("Expected a %s column, got schema: %v", name, reader.Schema())
+		}
+		leaves = append(leaves, leaf.ColumnIndex)
+	}
+
+	var records [][]string
+	rows := make([]parquet.Row, 1)
+	for {
+		n, err := reader.ReadRows(rows)
+		if n == 1 {
+			record := make([]string, len(names))
+			for i, index := range leaves {
+				value := rows[0][index]
+				switch {
+				case value.IsNull():
+				case value.Kind() == parquet.Int64:
+					record[i] = strconv.FormatInt(value.Int64(), 10)
+				default:
+					record[i] = string(value.ByteArray())
+				}
+			}
+			records = append(records, record)
+		}
+
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("Failed to read parquet rows: %v", err)
+		}
+	}
+}
+
+func TestParquetWriteData_MatchesCSV(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Rows = 50
+	opts.Fields = "id,name,age,email"
+	opts.NullRate = 0.2
+	opts.Seed = 1
+	opts.Quiet = true
+
+	var csvData bytes.Buffer
+	if err := (CSVDataGenerator{}).writeData(context.Background(), &csvData, false, opts, CSVFileWriter{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	records, err := csv.NewReader(&csvData).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV data: %v", err)
+	}
+
+	opts.Format = "parquet"
+	var parquetData bytes.Buffer
+	if err := (ParquetDataGenerator{}).writeData(context.Background(), &parquetData, false, opts, CSVFileWriter{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	rows := readParquet(t, parquetData.Bytes(), records[0])
+	if len(rows) != len(records)-1 {
+		t.Fatalf("Expected %d rows, got %d", len(records)-1, len(rows))
+	}
+
+	for i, row := range rows {
+		for j, value := range row {
+			if value != records[i+1][j] {
+				t.Errorf("Row %d: expected %s to be %q, got %q", i+1, records[0][j], records[i+1][j], value)
+			}
+		}
+	}
+
+	file, err := parquet.OpenFile(bytes.NewReader(parquetData.Bytes()), int64(parquetData.Len()))
+	if err != nil {
+		t.Fatalf("Failed to open parquet file: %v", err)
+	}
+
+	for _, field := range file.Schema().Fields() {
+		expected := parquet.ByteArray
+		if field.Name() == "age" {
+			expected = parquet.Int64
+		}
+		if kind := field.Type().Kind(); kind != expected || !field.Optional() {
+			t.Errorf("Expected %s to be an optional %v column, got %v", field.Name(), expected, kind)
+		}
+	}
+}
+
+func TestParquetWriteData_KeepFieldCase(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Format = "parquet"
+	opts.Rows = 3
+	opts.Fields = "AGE,name"
+	opts.KeepFieldCase = true
+	opts.Seed = 1
+	opts.Quiet = true
+
+	var data bytes.Buffer
+	if err := (ParquetDataGenerator{}).writeData(context.Background(), &data, false, opts, CSVFileWriter{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	file, err := parquet.OpenFile(bytes.NewReader(data.Bytes()), int64(data.Len()))
+	if err != nil {
+		t.Fatalf("Failed to open parquet file: %v", err)
+	}
+
+	// The column keeps the case of the header, and the type of the age field.
+	leaf, ok := file.Schema().Lookup("AGE")
+	if !ok || leaf.Node.Type().Kind() != parquet.Int64 {
+		t.Fatalf("Expected an AGE column of integers, got schema: %v", file.Schema())
+	}
+
+	for _, row := range readParquet(t, data.Bytes(), []string{"AGE", "name"}) {
+		if _, err := strconv.Atoi(row[0]); err != nil || row[1] == "" {
+			t.Errorf("Expected an integer age and a name, got %v", row)
+		}
+	}
+}
+
+func TestParquetWriteData_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		hasContent    bool
+		modify        func(*Options)
+		expectedError string
+	}{
+		{
+			name:          "Existing content",
+			hasContent:    true,
+			expectedError: "parquet files can't be appended to",
+		},
+		{
+			name:          "Max bytes",
+			modify:        func(o *Options) { o.MaxBytes = 1000 },
+			expectedError: "parquet output can't be limited to a number of bytes",
+		},
+		{
+			name:          "Duplicate column",
+			modify:        func(o *Options) { o.Fields = "name,name" },
+			expectedError: "parquet columns must have different names, name is selected more than once",
+		},
+		{
+			name:          "Formatted age",
+			modify:        func(o *Options) { o.IntFormat = "%d years" },
+			expectedError: "age must be an integer in parquet output, got \"59 years\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.Format = "parquet"
+			opts.Rows = 1
+			opts.Fields = "name,age"
+			opts.Seed = 1
+			opts.Quiet = true
+			if tt.modify != nil {
+				tt.modify(&opts)
+			}
+
+			err := (ParquetDataGenerator{}).writeData(context.Background(), io.Discard, tt.hasContent, opts, CSVFileWriter{})
+			if err == nil || err.Error() != tt.expectedError {
+				t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
+			}
+		})
+	}
+}
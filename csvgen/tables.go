@@ -0,0 +1,219 @@
+package csvgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"strings"
+)
+
+// Schema describes a set of related tables generated together by GenerateTables.
+type Schema struct {
+	Tables []Table `json:"tables"`
+}
+
+// Table is a single table of a Schema, written to its own file named after the table.
+type Table struct {
+	Name   string `json:"name"`
+	Rows   int64  `json:"rows"`
+	Fields string `json:"fields"`
+	// ForeignKeys are columns added after Fields holding the id of a row in another
+	// table.
+	ForeignKeys []ForeignKey `json:"foreignKeys"`
+}
+
+// ForeignKey is a column holding the id of a random row of the referenced table, which
+// must select the id field.
+type ForeignKey struct {
+	Column     string `json:"column"`
+	References string `json:"references"`
+}
+
+// ReadSchemaFile reads a Schema from the JSON file at path.
+func ReadSchemaFile(path string) (Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Schema{}, fmt.Errorf("failed to read schema file: %v", err)
+	}
+
+	var schema Schema
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&schema); err != nil {
+		return Schema{}, fmt.Errorf("invalid schema file: %v", err)
+	}
+
+	return schema, nil
+}
+
+// GenerateTables generates every table of schema into opts.OutputDir, using opts for the
+// settings shared by all the tables. Rows, Fields and Filename are taken from each table
+// instead, and Unique and Report are ignored. Every table is validated before any file
+// is written, and opts.Manifest lists the file of every table.
+func GenerateTables(schema Schema, opts Options) error {
+	return GenerateTablesContext(context.Background(), schema, opts)
+}
+
+// GenerateTablesContext is like GenerateTables, but stops generating rows once ctx is
+// cancelled. The rows of the table being written are kept as with GenerateContext, and
+// the tables after it aren't written.
+func GenerateTablesContext(ctx context.Context, schema Schema, opts Options) error {
+	if opts.Manifest != "" {
+		opts.manifest = &Manifest{}
+	}
+
+	tableOpts, err := prepareTables(schema, opts)
+	if err != nil {
+		return err
+	}
+
+	for _, opts := range tableOpts {
+		if err := generate(ctx, OSFileHandler{}, CSVFileWriter{}, dataGenerators[opts.Format], opts); err != nil {
+			return err
+		}
+	}
+
+	return writeManifest(opts, OSFileHandler{})
+}
+
+// tableExtensions maps each supported output format to the extension of the files its
+// tables are written to.
+var tableExtensions = map[string]string{
+	"csv":  ".csv",
+	"json": ".json",
+}
+
+// prepareTables validates schema and returns the options each of its tables is generated
+// with, in the order the tables are defined.
+func prepareTables(schema Schema, opts Options) ([]Options, error) {
+	if len(schema.Tables) == 0 {
+		return nil, fmt.Errorf("Invalid schema: no tables defined")
+	}
+
+	if opts.Filename == stdoutFilename {
+		return nil, fmt.Errorf("Invalid schema: tables can't be written to stdout")
+	}
+
+	// The widths would have to match the columns of every table.
+	if opts.Format == "fixed" {
+		return nil, fmt.Errorf("Invalid schema: tables can't be written with fixed width columns")
+	}
+
+	// Foreign keys are drawn from the number of rows of the table they reference.
+	if opts.MaxBytes > 0 {
+		return nil, fmt.Errorf("Invalid schema: tables can't be limited to a number of bytes")
+	}
+
+	// A seed is picked up front so the whole set of tables can be reproduced from it,
+	// each table derives its own seed from it so tables with the same fields differ.
+	if opts.Seed == 0 {
+		opts.Seed = randomSeed()
+	}
+	fmt.Fprintf(opts.logOutput(), "Schema seed: %d\n", opts.Seed)
+
+	tables := map[string]Table{}
+	referenced := map[string]bool{}
+	for i, table := range schema.Tables {
+		if table.Name == "" {
+			return nil, fmt.Errorf("Invalid schema: table %d has no name", i+1)
+		}
+		if _, ok := tables[table.Name]; ok {
+			return nil, fmt.Errorf("Invalid schema: table %q is defined more than once", table.Name)
+		}
+		tables[table.Name] = table
+
+		for _, key := range table.ForeignKeys {
+			referenced[key.References] = true
+		}
+	}
+
+	var tableOpts []Options
+	for i, table := range schema.Tables {
+		t := opts
+		t.Rows = table.Rows
+		t.Fields = table.Fields
+		t.Filename = table.Name + tableExtensions[opts.Format]
+		t.Seed = tableSeed(opts.Seed, i)
+		t.Unique = ""
+		t.Report = ""
+		t.referenced = referenced[table.Name]
+
+		for _, key := range table.ForeignKeys {
+			col, err := newForeignKeyColumn(key, tables)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid schema: table %q: %v", table.Name, err)
+			}
+			t.foreignKeys = append(t.foreignKeys, col)
+		}
+
+		t, err := prepare(t)
+		if err != nil {
+			return nil, fmt.Errorf("Table %q: %v", table.Name, err)
+		}
+
+		if err := validateForeignKeyColumns(t); err != nil {
+			return nil, fmt.Errorf("Invalid schema: table %q: %v", table.Name, err)
+		}
+
+		tableOpts = append(tableOpts, t)
+	}
+
+	return tableOpts, nil
+}
+
+// tableSeed derives the seed of the table at index from the seed of a set of tables.
+func tableSeed(seed int, index int) int {
+	return rand.New(rand.NewPCG(uint64(seed), uint64(index))).IntN(math.MaxInt) + 1
+}
+
+// newForeignKeyColumn returns the column for key. The ids of a table are sequential from
+// 1, so a random number up to the referenced table's row count is always the id of one
+// of its rows. It's padded like the ids, so it matches the id it references.
+func newForeignKeyColumn(key ForeignKey, tables map[string]Table) (column, error) {
+	if key.Column == "" {
+		return column{}, fmt.Errorf("foreign key referencing %q has no column", key.References)
+	}
+
+	parent, ok := tables[key.References]
+	if !ok {
+		return column{}, fmt.Errorf("foreign key %s references unknown table %q", key.Column, key.References)
+	}
+
+	selectsID := strings.EqualFold(parent.Fields, allFields)
+	for _, field := range splitFields(parent.Fields) {
+		// The id can be annotated, e.g. id@5, and a field that doesn't parse is reported
+		// when the parent table is validated.
+		col, err := parseColumn(field)
+		selectsID = selectsID || (err == nil && col.name == "id")
+	}
+	if !selectsID {
+		return column{}, fmt.Errorf("foreign key %s references table %q, which doesn't select the id field", key.Column, key.References)
+	}
+
+	parentRows := int(max(parent.Rows, 1))
+	generate := func(rc rowContext) string { return formatID(int64(rc.faker.Number(1, parentRows)), rc.opts) }
+
+	return column{name: key.Column, generate: generate, notNull: true}, nil
+}
+
+// validateForeignKeyColumns checks the foreign key columns of a table don't have the same
+// name as another of its columns.
+func validateForeignKeyColumns(opts Options) error {
+	names := map[string]bool{}
+	for _, field := range splitFields(opts.Fields) {
+		names[field] = true
+	}
+
+	for _, col := range opts.foreignKeys {
+		if names[col.name] {
+			return fmt.Errorf("foreign key column %s is already a column of the table", col.name)
+		}
+		names[col.name] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package csvgen
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// readTable returns the header and records of a table generated into dir.
+func readTable(t *testing.T, dir string, name string) ([]string, [][]string) {
+	t.Helper()
+
+	file, err := os.Open(filepath.Join(dir, name+".csv"))
+	if err != nil {
+		t.Fatalf("Failed to open table %s: %v", name, err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read table %s: %v", name, err)
+	}
+
+	return records[0], records[1:]
+}
+
+func TestGenerateTables_ReferentialIntegrity(t *testing.T) {
+	schema := Schema{Tables: []Table{
+		{Name: "orders", Rows: 1000, Fields: "id,price", ForeignKeys: []ForeignKey{{Column: "userId", References: "users"}}},
+		{Name: "users", Rows: 20, Fields: "id,name,email"},
+	}}
+
+	opts := DefaultOptions()
+	opts.OutputDir = t.TempDir()
+	opts.Workers = 4
+	opts.NullRate = 0.5
+	opts.IDWidth = 3
+	opts.Seed = 1
+
+	if err := GenerateTables(schema, opts); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	userHeader, users := readTable(t, opts.OutputDir, "users")
+	orderHeader, orders := readTable(t, opts.OutputDir, "orders")
+
+	if len(users) != 20 || len(orders) != 1000 {
+		t.Fatalf("Expected 20 users and 1000 orders, got %d and %d", len(users), len(orders))
+	}
+
+	if userHeader[0] != "id" || orderHeader[2] != "userId" {
+		t.Fatalf("Unexpected headers: %v and %v", userHeader, orderHeader)
+	}
+
+	userIDs := map[string]bool{}
+	for _, user := range users {
+		if len(user[0]) != 3 {
+			t.Fatalf("Expected every referenced id to be set and padded, got: %v", user)
+		}
+		userIDs[user[0]] = true
+	}
+
+	for _, order := range orders {
+		if !userIDs[order[2]] {
+			t.Fatalf("Order %v references a user that doesn't exist", order)
+		}
+	}
+}
+
+func TestGenerateTables_Reproducible(t *testing.T) {
+	schema := Schema{Tables: []Table{
+		{Name: "users", Rows: 5, Fields: "id,name"},
+		{Name: "admins", Rows: 5, Fields: "id,name"},
+	}}
+
+	generateUsers := func() ([][]string, [][]string) {
+		opts := DefaultOptions()
+		opts.OutputDir = t.TempDir()
+		opts.Seed = 1
+
+		if err := GenerateTables(schema, opts); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		_, users := readTable(t, opts.OutputDir, "users")
+		_, admins := readTable(t, opts.OutputDir, "admins")
+		return users, admins
+	}
+
+	users, admins := generateUsers()
+	usersAgain, _ := generateUsers()
+
+	if users[0][1] != usersAgain[0][1] {
+		t.Errorf("Expected the same seed to generate the same users, got %v and %v", users, usersAgain)
+	}
+
+	if users[0][1] == admins[0][1] {
+		t.Errorf("Expected tables with the same fields to have different values, got %v for both", users[0])
+	}
+}
+
+func TestGenerateTables_AnnotatedParentID(t *testing.T) {
+	for _, fields := range []string{"id@5", "id?0.1", "id:upper", "ID"} {
+		t.Run(fields, func(t *testing.T) {
+			schema := Schema{Tables: []Table{
+				{Name: "users", Rows: 5, Fields: fields + ",name"},
+				{Name: "orders", Rows: 20, Fields: "id", ForeignKeys: []ForeignKey{{Column: "userId", References: "users"}}},
+			}}
+
+			opts := DefaultOptions()
+			opts.OutputDir = t.TempDir()
+			opts.Quiet = true
+			opts.Seed = 1
+			if err := GenerateTables(schema, opts); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			_, orders := readTable(t, opts.OutputDir, "orders")
+			for _, order := range orders {
+				if id, err := strconv.Atoi(order[1]); err != nil || id < 1 || id > 5 {
+					t.Errorf("Expected a user id between 1 and 5, got %q", order[1])
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateTables_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		tables        []Table
+		expectedError string
+	}{
+		{
+			name:          "No tables",
+			expectedError: "Invalid schema: no tables defined",
+		},
+		{
+			name:          "Missing table name",
+			tables:        []Table{{Rows: 1, Fields: "id"}},
+			expectedError: "Invalid schema: table 1 has no name",
+		},
+		{
+			name:          "Duplicate table",
+			tables:        []Table{{Name: "users", Rows: 1, Fields: "id"}, {Name: "users", Rows: 1, Fields: "id"}},
+			expectedError: "Invalid schema: table \"users\" is defined more than once",
+		},
+		{
+			name:          "Unknown referenced table",
+			tables:        []Table{{Name: "orders", Rows: 1, Fields: "id", ForeignKeys: []ForeignKey{{Column: "userId", References: "users"}}}},
+			expectedError: "Invalid schema: table \"orders\": foreign key userId references unknown table \"users\"",
+		},
+		{
+			name: "Referenced table without ids",
+			tables: []Table{
+				{Name: "users", Rows: 1, Fields: "name"},
+				{Name: "orders", Rows: 1, Fields: "id", ForeignKeys: []ForeignKey{{Column: "userId", References: "users"}}},
+			},
+			expectedError: "Invalid schema: table \"orders\": foreign key userId references table \"users\", which doesn't select the id field",
+		},
+		{
+			name:          "Foreign key without a column",
+			tables:        []Table{{Name: "users", Rows: 1, Fields: "id", ForeignKeys: []ForeignKey{{References: "users"}}}},
+			expectedError: "Invalid schema: table \"users\": foreign key referencing \"users\" has no column",
+		},
+		{
+			name:          "Foreign key column repeats a field",
+			tables:        []Table{{Name: "users", Rows: 1, Fields: "id", ForeignKeys: []ForeignKey{{Column: "id", References: "users"}}}},
+			expectedError: "Invalid schema: table \"users\": foreign key column id is already a column of the table",
+		},
+		{
+			name:          "Invalid table options",
+			tables:        []Table{{Name: "users", Rows: 0, Fields: "id"}},
+			expectedError: "Table \"users\": Invalid options: invalid number of rows: 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.OutputDir = t.TempDir()
+
+			err := GenerateTables(Schema{Tables: tt.tables}, opts)
+			if err == nil || err.Error() != tt.expectedError {
+				t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
+			}
+
+			if entries, _ := os.ReadDir(opts.OutputDir); len(entries) != 0 {
+				t.Errorf("Expected no files to be written, got %d", len(entries))
+			}
+		})
+	}
+}
+
+func TestGenerateTablesContext_Cancelled(t *testing.T) {
+	schema := Schema{Tables: []Table{
+		{Name: "users", Rows: 10, Fields: "id,name"},
+		{Name: "products", Rows: 10, Fields: "id,price"},
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	opts := DefaultOptions()
+	opts.OutputDir = t.TempDir()
+	opts.Quiet = true
+	if err := GenerateTablesContext(ctx, schema, opts); err == nil {
+		t.Fatalf("Expected the generation to be cancelled")
+	}
+
+	// The cancelled table is kept, without its temporary file, and the next isn't started.
+	entries, _ := os.ReadDir(opts.OutputDir)
+	if len(entries) != 1 || entries[0].Name() != "users.csv" {
+		t.Errorf("Expected only users.csv to be written, got: %v", entries)
+	}
+}
+
+func TestReadSchemaFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.json")
+	data := `{"tables": [{"name": "users", "rows": 2, "fields": "id,name"}, {"name": "orders", "rows": 3, "fields": "id", "foreignKeys": [{"column": "userId", "references": "users"}]}]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("Failed to write schema file: %v", err)
+	}
+
+	schema, err := ReadSchemaFile(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(schema.Tables) != 2 || schema.Tables[1].ForeignKeys[0] != (ForeignKey{Column: "userId", References: "users"}) {
+		t.Errorf("Unexpected schema: %+v", schema)
+	}
+
+	unknownPath := filepath.Join(dir, "unknown.json")
+	if err := os.WriteFile(unknownPath, []byte(`{"tables": [{"name": "users", "row": 2}]}`), 0o644); err != nil {
+		t.Fatalf("Failed to write schema file: %v", err)
+	}
+
+	expectedError := `invalid schema file: json: unknown field "row"`
+	if _, err := ReadSchemaFile(unknownPath); err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error: %v\nGot: %v", expectedError, err)
+	}
+}
@@ -1,206 +1,186 @@
 package main
 
 import (
-	"encoding/csv"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
-	"strconv"
-	"strings"
-	"time"
+	"os/signal"
+	"runtime"
+	"runtime/debug"
+	"syscall"
 
-	"github.com/brianvoe/gofakeit/v7"
+	"go-test-csv-generator/csvgen"
 )
 
-type FileHandler interface {
-	MkDirAll(path string, perm os.FileMode) error
-	Create(name string) (*os.File, error)
-}
-
-type OSFileHandler struct{}
-
-func (c OSFileHandler) MkDirAll(path string, perm os.FileMode) error {
-	return os.MkdirAll(path, perm)
-}
-
-func (c OSFileHandler) Create(name string) (*os.File, error) {
-	return os.Create(name)
-}
-
-type FileWriter interface {
-	Write(record []string, writer *csv.Writer) error
-}
-
-type CSVFileWriter struct{}
-
-func (c CSVFileWriter) Write(record []string, writer *csv.Writer) error {
-	return writer.Write(record)
-}
-
-var validFields = map[string]bool{
-	"name":       true,
-	"age":        true,
-	"email":      true,
-	"firstName":  true,
-	"lastName":   true,
-	"middleName": true,
-	"city":       true,
-	"jobTitle":   true,
-}
+// version is the release version of the tool, set at build time with
+// -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+// versionString describes the running build, including the Go version and the git
+// commit it was built from when that information was embedded by the Go toolchain.
+func versionString() string {
+	commit := "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				commit = setting.Value
+			}
+		}
+	}
 
-var generators = map[string]func(BaseFields) string{
-	"name":       func(fields BaseFields) string { return fields.Name },
-	"age":        func(fields BaseFields) string { return strconv.Itoa(gofakeit.Number(18, 99)) },
-	"email":      func(fields BaseFields) string { return fields.Email },
-	"firstName":  func(fields BaseFields) string { return fields.FirstName },
-	"lastName":   func(fields BaseFields) string { return fields.LastName },
-	"middleName": func(fields BaseFields) string { return gofakeit.MiddleName() },
-	"city":       func(fields BaseFields) string { return gofakeit.City() },
-	"jobTitle":   func(fields BaseFields) string { return gofakeit.JobTitle() },
+	return fmt.Sprintf("go-test-csv-generator %s (commit %s, %s)", version, commit, runtime.Version())
 }
 
-type BaseFields struct {
-	Name      string
-	FirstName string
-	LastName  string
-	Email     string
+// flagError is an error parsing the command line arguments, which the flag set has already
+// printed along with the usage.
+type flagError struct {
+	err error
 }
 
-func validateFlags(rows int, fields string, filename string) error {
-	if rows <= 0 {
-		return fmt.Errorf("invalid number of rows: %d", rows)
+func (e flagError) Error() string { return e.err.Error() }
+
+func (e flagError) Unwrap() error { return e.err }
+
+// run parses the command line arguments (excluding the program name) and generates
+// the requested CSV file. Any error is returned to main to be reported to the user.
+func run(args []string) error {
+	flags := flag.NewFlagSet("go-test-csv-generator", flag.ContinueOnError)
+
+	defaults := csvgen.DefaultOptions()
+	opts := csvgen.Options{Log: os.Stderr}
+	flags.Int64Var(&opts.Rows, "rows", defaults.Rows, "Number of rows to include in the generated CSV file.")
+	flags.Int64Var(&opts.Rows, "n", defaults.Rows, "Alias for -rows.")
+	flags.Int64Var(&opts.MaxBytes, "maxbytes", defaults.MaxBytes, "Stop generating rows once the output reaches this many bytes, before compression, instead of after -rows rows. 0 disables the limit.")
+	flags.StringVar(&opts.Fields, "fields", defaults.Fields, "Comma separated list of fields (ex. 'name,age,int(1,1000)') to include in the generated CSV file. A field can be transformed with ':upper', ':lower' or ':title' (ex. 'email:lower').")
+	flags.IntVar(&opts.MaxFields, "maxfields", defaults.MaxFields, "Maximum number of fields that can be selected, to catch a mistaken -fields list.")
+	flags.StringVar(&opts.Filename, "filename", defaults.Filename, "Name of the file to write the generated CSV data to, or '-' to write to stdout.")
+	flags.StringVar(&opts.OutputDir, "outdir", defaults.OutputDir, "Directory to write the generated CSV file to.")
+	flags.StringVar(&opts.DirPerm, "dirperm", defaults.DirPerm, "Octal permissions of the output directory when it's created (ex. '0700').")
+	flags.StringVar(&opts.Delimiter, "delimiter", defaults.Delimiter, "Single character used to separate fields (ex. ';' or '\\t' for tab separated output).")
+	flags.StringVar(&opts.Format, "format", defaults.Format, "Output format, either 'csv', 'json' (newline delimited JSON), 'fixed' (fixed width columns) or 'parquet' (when built with -tags parquet).")
+	flags.StringVar(&opts.Widths, "widths", defaults.Widths, "Comma separated width of each column for -format fixed (ex. '20,3,30').")
+	flags.StringVar(&opts.Locale, "locale", defaults.Locale, "Locale of the generated names and addresses, only 'en-US' is currently supported.")
+	flags.StringVar(&opts.DateFormat, "dateformat", defaults.DateFormat, "Go time layout used to format date fields (ex. '02/01/2006').")
+	flags.StringVar(&opts.IntFormat, "intformat", defaults.IntFormat, "Printf style format for integer fields such as age, salary and int(min,max) (ex. '%03d' or '%d years').")
+	flags.StringVar(&opts.BoolFormat, "boolformat", defaults.BoolFormat, "True and false values for boolean fields separated by '/' (ex. 'yes/no').")
+	flags.IntVar(&opts.IDWidth, "idwidth", defaults.IDWidth, "Zero pad the id field to this many digits (ex. 6 for 000042). Longer ids are written in full.")
+	flags.IntVar(&opts.CoordPrecision, "coordprecision", defaults.CoordPrecision, "Number of decimal places in latitude and longitude fields.")
+	flags.IntVar(&opts.Precision, "precision", defaults.Precision, "Number of decimal places in all decimal fields (price, progress, latitude and longitude), overriding -coordprecision. -1 keeps each field's default.")
+	flags.Float64Var(&opts.NullRate, "nullrate", defaults.NullRate, "Rate between 0 and 1 at which generated values are replaced with an empty value.")
+	flags.IntVar(&opts.Workers, "workers", defaults.Workers, "Number of goroutines used to generate rows.")
+	flags.IntVar(&opts.Retries, "retries", defaults.Retries, "Number of times to retry creating the output directory and file when it fails, waiting longer before each retry.")
+	flags.BoolVar(&opts.Gzip, "gzip", defaults.Gzip, "Compress the output with gzip, appending '.gz' to the filename if needed.")
+	flags.BoolVar(&opts.Append, "append", defaults.Append, "Append to the file instead of overwriting it, skipping the header row if the file isn't empty.")
+	flags.BoolVar(&opts.Overwrite, "overwrite", defaults.Overwrite, "Replace the file if it already exists. With -overwrite=false an existing file is an error.")
+	flags.BoolVar(&opts.KeepPartial, "keeppartial", defaults.KeepPartial, "Keep the incomplete file when generating the data fails, instead of removing it.")
+	flags.BoolVar(&opts.AlwaysQuote, "alwaysquote", defaults.AlwaysQuote, "Quote every CSV field, not just the fields that need quoting.")
+	flags.BoolVar(&opts.CRLF, "crlf", defaults.CRLF, "End CSV and fixed width lines with \\r\\n instead of \\n, as expected by Windows tools such as Excel.")
+	flags.BoolVar(&opts.BOM, "bom", defaults.BOM, "Start the CSV file with a UTF-8 byte order mark, so Excel reads it as UTF-8.")
+	flags.BoolVar(&opts.Comment, "comment", defaults.Comment, "Start the CSV file with a '# generated by go-test-csv-generator seed=N' comment line before the header.")
+	flags.BoolVar(&opts.Static, "static", defaults.Static, "Generate a single row and repeat it for every row, e.g. for load tests or as a performance baseline.")
+	flags.BoolVar(&opts.ShuffleColumns, "shufflecolumns", defaults.ShuffleColumns, "Write the columns in a random order drawn from the seed, instead of the order of -fields.")
+	flags.BoolVar(&opts.KeepFieldCase, "keepfieldcase", defaults.KeepFieldCase, "Write the header row with the fields in the case they're given in -fields, instead of each field's canonical name.")
+	flags.BoolVar(&opts.Verify, "verify", defaults.Verify, "Read the CSV file back once it's written and fail if any row doesn't have a field for every column.")
+	flags.StringVar(&opts.EmailPrefix, "emailprefix", defaults.EmailPrefix, "Prefix added to the local part of every email address (ex. 'test+' for test+jane.doe@example.com).")
+	flags.StringVar(&opts.Unique, "unique", defaults.Unique, "Selected field whose values must not repeat across rows (ex. 'uuid' or 'int(1,1000000)').")
+	flags.BoolVar(&opts.AllowDuplicates, "allowduplicates", defaults.AllowDuplicates, "Allow a field to be selected more than once in -fields.")
+	flags.StringVar(&opts.Report, "report", defaults.Report, "Write a JSON summary of the run to this path, or '-' for stderr.")
+	flags.StringVar(&opts.Manifest, "manifest", defaults.Manifest, "Write a JSON list of every file written and its number of rows to this path, or '-' for stderr.")
+	flags.BoolVar(&opts.Quiet, "quiet", defaults.Quiet, "Don't print progress updates while generating rows.")
+	flags.IntVar(&opts.Seed, "seed", defaults.Seed, "Seed for random number generation. When 0 a random seed is used and printed.")
+	dryRun := flags.Bool("dryrun", false, "Validate the flags and print the header and a sample row to stdout without writing a file.")
+	estimate := flags.Bool("estimate", false, "Print an estimate of the output size to stderr, measured from a sample of 100 rows, without writing a file.")
+	countOnly := flags.Bool("countonly", false, "Generate the rows without writing them and print how many rows per second were generated, to profile the generators without disk I/O.")
+	fieldsFile := flags.String("fieldsfile", "", "File to read the list of fields from, separated by commas or newlines, instead of -fields.")
+	schemaFile := flags.String("schema", "", "JSON file describing multiple related tables to generate, one file per table, instead of -fields.")
+	showVersion := flags.Bool("version", false, "Print version information and exit.")
+
+	if err := flags.Parse(args); err != nil {
+		return flagError{err}
 	}
 
-	if fields == "" {
-		return fmt.Errorf("fields cannot be empty")
+	if *showVersion {
+		fmt.Println(versionString())
+		return nil
 	}
 
-	if filename == "" {
-		return fmt.Errorf("filename cannot be empty")
+	if *fieldsFile != "" {
+		fields, err := csvgen.ReadFieldsFile(*fieldsFile)
+		if err != nil {
+			return fmt.Errorf("Invalid options: %v", err)
+		}
+		opts.Fields = fields
 	}
 
-	return nil
-}
+	if *schemaFile != "" {
+		if *dryRun {
+			return fmt.Errorf("Invalid options: -dryrun can't be used with -schema")
+		}
+		if *estimate {
+			return fmt.Errorf("Invalid options: -estimate can't be used with -schema")
+		}
+		if *countOnly {
+			return fmt.Errorf("Invalid options: -countonly can't be used with -schema")
+		}
 
-func validateSelectedFields(fields string) []string {
-	var invalidFields []string
-	fieldSlice := strings.Split(fields, ",")
-	for _, userField := range fieldSlice {
-		if !validFields[userField] {
-			invalidFields = append(invalidFields, userField)
+		schema, err := csvgen.ReadSchemaFile(*schemaFile)
+		if err != nil {
+			return fmt.Errorf("Invalid options: %v", err)
 		}
-	}
 
-	return invalidFields
-}
+		ctx, stop := notifyInterrupt()
+		defer stop()
 
-// To maintain consistency between certain fields, base fields are generated for each row
-// regardless of whether they are included in the fields list.
-func generateBaseFields() BaseFields {
-	firstName := gofakeit.FirstName()
-	lastName := gofakeit.LastName()
-	emailDomain := gofakeit.DomainName()
-	name := fmt.Sprintf("%s %s", firstName, lastName)
-	email := fmt.Sprintf("%s.%s@%s", strings.ToLower(firstName), strings.ToLower(lastName), emailDomain)
-
-	return BaseFields{
-		Name:      name,
-		FirstName: firstName,
-		LastName:  lastName,
-		Email:     email,
+		return csvgen.GenerateTablesContext(ctx, schema, opts)
 	}
-}
 
-type DataGenerator interface {
-	generateCsvData(rows int, fields string, outputDir string, filename string, fileHandler FileHandler, csvWriter FileWriter) error
-}
-type CSVDataGenerator struct{}
+	if *estimate {
+		if opts.MaxBytes > 0 {
+			return fmt.Errorf("Invalid options: -estimate can't be used with -maxbytes")
+		}
 
-func (d CSVDataGenerator) generateCsvData(rows int, fields string, outputDir string, filename string, fileHandler FileHandler, csvWriter FileWriter) error {
-	if err := fileHandler.MkDirAll(outputDir, os.ModePerm); err != nil {
-		return fmt.Errorf("failed to create directory: %v", err)
+		return csvgen.Estimate(os.Stderr, opts)
 	}
 
-	filePath := filepath.Join(outputDir, filename)
-	file, err := fileHandler.Create(filePath)
-	if err != nil {
-		return err
+	if *countOnly {
+		return csvgen.CountOnly(os.Stderr, opts)
 	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	fieldSlice := strings.Split(fields, ",")
 
-	if err := csvWriter.Write(fieldSlice, writer); err != nil {
-		return fmt.Errorf("failed to write header row: %v", err)
+	if *dryRun {
+		return csvgen.Preview(os.Stdout, opts)
 	}
 
-	for i := 0; i < rows; i++ {
-		row := []string{}
-		baseFields := generateBaseFields()
-		for _, field := range fieldSlice {
-			row = append(row, generators[field](baseFields))
-		}
+	ctx, stop := notifyInterrupt()
+	defer stop()
 
-		if err := csvWriter.Write(row, writer); err != nil {
-			return fmt.Errorf("failed to write row: %v", err)
-		}
-	}
-
-	return nil
+	return csvgen.GenerateContext(ctx, opts)
 }
 
-func generate(
-	fileHandler FileHandler,
-	writer FileWriter,
-	generator DataGenerator,
-	rows *int,
-	fields *string,
-	filename *string,
-	seed *int,
-) {
-	startTime := time.Now()
-
-	fmt.Printf("Rows: %d\n", *rows)
-	fmt.Printf("Fields: %s\n", *fields)
-	fmt.Printf("Filename: %s\n", *filename)
-	fmt.Printf("Generating CSV file...\n")
-
-	gofakeit.Seed(*seed)
-
-	outputDir := "output"
-	if err := generator.generateCsvData(*rows, *fields, outputDir, *filename, fileHandler, writer); err != nil {
-		panic(fmt.Sprintf("Failed to generate CSV data: %v", err))
-	}
-
-	elapsed := time.Since(startTime)
-
-	fmt.Printf("CSV file successfully generated at %s/%s.\n", outputDir, *filename)
-	fmt.Printf("(Elapsed time: %f seconds)\n", elapsed.Seconds())
+// notifyInterrupt returns a context that's cancelled on SIGINT or SIGTERM, so an
+// interrupted run still flushes the rows written so far and closes the file before
+// exiting with an error. Once the first signal is received the default behaviour is
+// restored, so a second one stops the program straight away.
+func notifyInterrupt() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	return ctx, stop
 }
 
 func main() {
-	fileHandler := OSFileHandler{}
-	csvWriter := CSVFileWriter{}
-	generator := CSVDataGenerator{}
-
-	rows := flag.Int("rows", 1, "Number of rows to include in the generated CSV file.")
-	fields := flag.String("fields", "name,age", "Comma separated list of fields (ex. 'name,age,email') to include in the generated CSV file.")
-	filename := flag.String("filename", "output.csv", "Name of the file to write the generated CSV data to.")
-	seed := flag.Int("seed", 0, "Seed for random number generation.")
-	flag.Parse()
-
-	if err := validateFlags(*rows, *fields, *filename); err != nil {
-		panic(fmt.Sprintf("Invalid flags: %v", err))
-	}
+	if err := run(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
 
-	invalidFields := validateSelectedFields(*fields)
-	if len(invalidFields) > 0 {
-		panic(fmt.Sprintf("Unable to generate CSV data. Invalid fields selected: %s", strings.Join(invalidFields, ", ")))
+		if !errors.As(err, &flagError{}) {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(1)
 	}
-
-	generate(fileHandler, csvWriter, generator, rows, fields, filename, seed)
 }
@@ -2,367 +2,2188 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/csv"
-	"flag"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"go-test-csv-generator/csvgen"
 )
 
-type MockDataGenerator struct {
-	ShouldFail bool
+func TestRun_ErrorCases(t *testing.T) {
+	validFieldList := strings.Join(csvgen.SupportedFields(), ", ")
+
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError string
+	}{
+		{
+			name:          "Less than 1 row",
+			args:          []string{"-rows", "0"},
+			expectedError: "Invalid options: invalid number of rows: 0",
+		},
+		{
+			name:          "Negative rows using alias",
+			args:          []string{"-n", "-5"},
+			expectedError: "Invalid options: invalid number of rows: -5",
+		},
+		{
+			name:          "Negative max bytes",
+			args:          []string{"-maxbytes", "-1"},
+			expectedError: "Invalid options: invalid maximum number of bytes: -1",
+		},
+		{
+			name:          "Invalid max fields",
+			args:          []string{"-maxfields", "0"},
+			expectedError: "Invalid options: invalid maximum number of fields: 0",
+		},
+		{
+			name:          "Too many fields",
+			args:          []string{"-fields", "name,age,email", "-maxfields", "2"},
+			expectedError: "Invalid options: 3 fields selected, more than the maximum of 2",
+		},
+		{
+			name:          "Negative id width",
+			args:          []string{"-idwidth", "-1"},
+			expectedError: "Invalid options: invalid id width: -1",
+		},
+		{
+			name:          "Rows overflow",
+			args:          []string{"-rows", "9223372036854775808"},
+			expectedError: "invalid value \"9223372036854775808\" for flag -rows: value out of range",
+		},
+		{
+			name:          "No fields",
+			args:          []string{"-fields", ""},
+			expectedError: "Invalid options: fields cannot be empty",
+		},
+		{
+			name:          "No output file name",
+			args:          []string{"-filename", ""},
+			expectedError: "Invalid options: filename cannot be empty",
+		},
+		{
+			name:          "Output file name with path traversal",
+			args:          []string{"-filename", "../../etc/passwd"},
+			expectedError: "Invalid options: filename cannot contain path separators: \"../../etc/passwd\"",
+		},
+		{
+			name:          "Output file name with Windows path separator",
+			args:          []string{"-filename", `..\output.csv`},
+			expectedError: "Invalid options: filename cannot contain path separators: \"..\\\\output.csv\"",
+		},
+		{
+			name:          "Output file name is the parent directory",
+			args:          []string{"-filename", ".."},
+			expectedError: "Invalid options: invalid filename: \"..\"",
+		},
+		{
+			name:          "No output directory",
+			args:          []string{"-outdir", ""},
+			expectedError: "Invalid options: output directory cannot be empty",
+		},
+		{
+			name:          "Multi-character delimiter",
+			args:          []string{"-delimiter", ";;"},
+			expectedError: "Invalid options: delimiter must be a single character: \";;\"",
+		},
+		{
+			name:          "Empty delimiter",
+			args:          []string{"-delimiter", ""},
+			expectedError: "Invalid options: delimiter must be a single character: \"\"",
+		},
+		{
+			name:          "Quote delimiter",
+			args:          []string{"-delimiter", `"`},
+			expectedError: "Invalid options: delimiter can't be a quote or line break: \"\\\"\"",
+		},
+		{
+			name:          "Carriage return delimiter",
+			args:          []string{"-delimiter", "\r"},
+			expectedError: "Invalid options: delimiter can't be a quote or line break: \"\\r\"",
+		},
+		{
+			name:          "Newline delimiter",
+			args:          []string{"-delimiter", "\n"},
+			expectedError: "Invalid options: delimiter can't be a quote or line break: \"\\n\"",
+		},
+		{
+			name:          "No date format",
+			args:          []string{"-dateformat", ""},
+			expectedError: "Invalid options: date format cannot be empty",
+		},
+		{
+			name:          "Boolean format without separator",
+			args:          []string{"-boolformat", "yes"},
+			expectedError: "Invalid options: boolean format must be two different values separated by '/': \"yes\"",
+		},
+		{
+			name:          "Boolean format with matching values",
+			args:          []string{"-boolformat", "1/1"},
+			expectedError: "Invalid options: boolean format must be two different values separated by '/': \"1/1\"",
+		},
+		{
+			name:          "Integer format without a verb",
+			args:          []string{"-intformat", "age"},
+			expectedError: "Invalid options: integer format must format a single integer: \"age\"",
+		},
+		{
+			name:          "Integer format with a string verb",
+			args:          []string{"-intformat", "%s"},
+			expectedError: "Invalid options: integer format must format a single integer: \"%s\"",
+		},
+		{
+			name:          "Verify with json output",
+			args:          []string{"-verify", "-format", "json"},
+			expectedError: "Invalid options: verification is only supported for csv output",
+		},
+		{
+			name:          "Verify when writing to stdout",
+			args:          []string{"-verify", "-filename", "-"},
+			expectedError: "Invalid options: verification can't be used when writing to stdout",
+		},
+		{
+			name:          "Invalid directory permissions",
+			args:          []string{"-dirperm", "0789"},
+			expectedError: "Invalid options: directory permissions must be an octal mode between 0000 and 0777: \"0789\"",
+		},
+		{
+			name:          "Directory permissions out of range",
+			args:          []string{"-dirperm", "1777"},
+			expectedError: "Invalid options: directory permissions must be an octal mode between 0000 and 0777: \"1777\"",
+		},
+		{
+			name:          "Negative number of retries",
+			args:          []string{"-retries", "-1"},
+			expectedError: "Invalid options: invalid number of retries: -1",
+		},
+		{
+			name:          "Negative coordinate precision",
+			args:          []string{"-coordprecision", "-1"},
+			expectedError: "Invalid options: invalid coordinate precision: -1",
+		},
+		{
+			name:          "Invalid precision",
+			args:          []string{"-precision", "-2"},
+			expectedError: "Invalid options: invalid precision: -2",
+		},
+		{
+			name:          "Null rate below 0",
+			args:          []string{"-nullrate", "-0.1"},
+			expectedError: "Invalid options: null rate must be between 0 and 1: -0.1",
+		},
+		{
+			name:          "Null rate above 1",
+			args:          []string{"-nullrate", "1.5"},
+			expectedError: "Invalid options: null rate must be between 0 and 1: 1.5",
+		},
+		{
+			name:          "No workers",
+			args:          []string{"-workers", "0"},
+			expectedError: "Invalid options: invalid number of workers: 0",
+		},
+		{
+			name:          "Invalid format",
+			args:          []string{"-format", "xml"},
+			expectedError: "Invalid options: invalid format: \"xml\"",
+		},
+		{
+			name:          "Fixed width without widths",
+			args:          []string{"-format", "fixed"},
+			expectedError: "Invalid options: fixed width output requires a width for every column",
+		},
+		{
+			name:          "Invalid column width",
+			args:          []string{"-format", "fixed", "-widths", "20,0"},
+			expectedError: "Invalid options: column widths must be positive integers: \"20,0\"",
+		},
+		{
+			name:          "Wrong number of column widths",
+			args:          []string{"-format", "fixed", "-widths", "20", "-fields", "name,age"},
+			expectedError: "Invalid options: 1 column widths given for 2 columns",
+		},
+		{
+			name:          "Column widths without fixed width output",
+			args:          []string{"-widths", "20,3"},
+			expectedError: "Invalid options: column widths are only supported for fixed width output",
+		},
+		{
+			name:          "BOM without CSV output",
+			args:          []string{"-bom", "-format", "json"},
+			expectedError: "Invalid options: a byte order mark is only supported for csv output",
+		},
+		{
+			name:          "Comment without CSV output",
+			args:          []string{"-comment", "-format", "json"},
+			expectedError: "Invalid options: a comment line is only supported for csv output",
+		},
+		{
+			name:          "Static rows with a unique field",
+			args:          []string{"-static", "-unique", "uuid", "-fields", "uuid"},
+			expectedError: "Invalid options: static rows can't have a unique field, as every row is the same",
+		},
+		{
+			name:          "Email prefix with an @",
+			args:          []string{"-emailprefix", "test@"},
+			expectedError: "Invalid options: email prefix can't contain '@' or whitespace: \"test@\"",
+		},
+		{
+			name:          "Progress with max bytes",
+			args:          []string{"-fields", "id,progress", "-maxbytes", "1000"},
+			expectedError: "Invalid options: the progress field can't be used with -maxbytes, as the number of rows isn't known",
+		},
+		{
+			name:          "Dry run still validates flags",
+			args:          []string{"-dryrun", "-rows", "0"},
+			expectedError: "Invalid options: invalid number of rows: 0",
+		},
+		{
+			name:          "Unsupported locale",
+			args:          []string{"-locale", "de-DE"},
+			expectedError: "Invalid options: unsupported locale \"de-DE\", supported locales are: en-US",
+		},
+		{
+			name:          "Empty locale",
+			args:          []string{"-locale", ""},
+			expectedError: "Invalid options: unsupported locale \"\", supported locales are: en-US",
+		},
+		{
+			name:          "Unknown flag",
+			args:          []string{"-unknown"},
+			expectedError: "flag provided but not defined: -unknown",
+		},
+		{
+			name:          "Invalid fields",
+			args:          []string{"-fields", "invalid"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: invalid. Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Integer range with min greater than max",
+			args:          []string{"-fields", "name,int(10,1)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: int(10,1) (min 10 is greater than max 1). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Integer range wider than an int",
+			args:          []string{"-fields", "int(0,9223372036854775807)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: int(0,9223372036854775807) (range is too wide). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Integer range of negative and positive values wider than an int",
+			args:          []string{"-fields", "int(-4611686018427387904,4611686018427387904)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: int(-4611686018427387904,4611686018427387904) (range is too wide). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Salary range wider than an int",
+			args:          []string{"-fields", "salary(-9223372036854775808,0)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: salary(-9223372036854775808,0) (range is too wide). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Integer range with invalid min",
+			args:          []string{"-fields", "int(a,10)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: int(a,10) (invalid min \"a\"). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Integer range with invalid max",
+			args:          []string{"-fields", "int(1,b)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: int(1,b) (invalid max \"b\"). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Integer range without max",
+			args:          []string{"-fields", "int(5)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: int(5) (expected min and max separated by a comma). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Unclosed integer range",
+			args:          []string{"-fields", "int(1,10"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: int(1,10. Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Price range with min greater than max",
+			args:          []string{"-fields", "price(20,9.99)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: price(20,9.99) (min 20 is greater than max 9.99). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Price range with invalid min",
+			args:          []string{"-fields", "price(x,10)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: price(x,10) (invalid min \"x\"). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Price range without max",
+			args:          []string{"-fields", "price(5)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: price(5) (expected min and max separated by a comma). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Salary range with min greater than max",
+			args:          []string{"-fields", "salary(90000,50000)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: salary(90000,50000) (min 90000 is greater than max 50000). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Sentence with no words",
+			args:          []string{"-fields", "sentence(0)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: sentence(0) (count must be positive: 0). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Paragraph with invalid count",
+			args:          []string{"-fields", "paragraph(many)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: paragraph(many) (invalid count \"many\"). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Enum without options",
+			args:          []string{"-fields", "enum()"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: enum() (options cannot be empty). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Enum with invalid weight",
+			args:          []string{"-fields", "enum(a:1,b:x)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: enum(a:1,b:x) (invalid weight \"x\" for option \"b\"). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Enum with zero weight",
+			args:          []string{"-fields", "enum(a:1,b:0)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: enum(a:1,b:0) (weight for option \"b\" must be a positive number: 0). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Enum with negative weight",
+			args:          []string{"-fields", "enum(a:-5)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: enum(a:-5) (weight for option \"a\" must be a positive number: -5). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Enum with weight too small for a float32",
+			args:          []string{"-fields", "enum(a:1,b:1e-50)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: enum(a:1,b:1e-50) (weight for option \"b\" is out of range: 1e-50). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Enum with weight too large for a float32",
+			args:          []string{"-fields", "enum(a:1e300,b:1)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: enum(a:1e300,b:1) (weight for option \"a\" is out of range: 1e+300). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Enum with weights adding up to more than a float32",
+			args:          []string{"-fields", "enum(a:3e38,b:3e38)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: enum(a:3e38,b:3e38) (weights must add up to at most 3.4028234663852886e+38). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Timestamp range with start after end",
+			args:          []string{"-fields", "timestamp(2024-01-01,2020-01-01)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: timestamp(2024-01-01,2020-01-01) (start \"2024-01-01\" is after end \"2020-01-01\"). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Timestamp range with invalid start",
+			args:          []string{"-fields", "timestamp(yesterday,2020-01-01)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: timestamp(yesterday,2020-01-01) (invalid start \"yesterday\"). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Timestamp range with invalid end",
+			args:          []string{"-fields", "timestamp(2020-01-01,2020-13-01)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: timestamp(2020-01-01,2020-13-01) (invalid end \"2020-13-01\"). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Timestamp range out of bounds",
+			args:          []string{"-fields", "timestamp(1000-01-01,2020-01-01)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: timestamp(1000-01-01,2020-01-01) (timestamps must be between 1677-09-21 and 2262-04-11). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Multiple invalid fields",
+			args:          []string{"-fields", "name,foo,bar"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: foo, bar. Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Dry run with a schema",
+			args:          []string{"-schema", "schema.json", "-dryrun"},
+			expectedError: "Invalid options: -dryrun can't be used with -schema",
+		},
+		{
+			name:          "Estimate with a schema",
+			args:          []string{"-schema", "schema.json", "-estimate"},
+			expectedError: "Invalid options: -estimate can't be used with -schema",
+		},
+		{
+			name:          "Count only with a schema",
+			args:          []string{"-schema", "schema.json", "-countonly"},
+			expectedError: "Invalid options: -countonly can't be used with -schema",
+		},
+		{
+			name:          "Estimate with max bytes",
+			args:          []string{"-estimate", "-maxbytes", "1000"},
+			expectedError: "Invalid options: -estimate can't be used with -maxbytes",
+		},
+		{
+			name:          "Missing schema file",
+			args:          []string{"-schema", "does-not-exist.json"},
+			expectedError: "Invalid options: failed to read schema file: open does-not-exist.json: no such file or directory",
+		},
+		{
+			name:          "Invalid field seed",
+			args:          []string{"-fields", "name,email@x"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: email@x (seed must be a positive integer: \"x\"). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Unique field with its own seed",
+			args:          []string{"-fields", "name,email@42", "-unique", "email"},
+			expectedError: "Failed to generate CSV data: unique field \"email\" can't have its own seed",
+		},
+		{
+			name:          "Missing values file",
+			args:          []string{"-fields", "name,fromfile(does-not-exist.txt)"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: fromfile(does-not-exist.txt) (failed to read values: open does-not-exist.txt: no such file or directory). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Invalid empty rate",
+			args:          []string{"-fields", "name,email?1.5"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: email?1.5 (empty rate must be between 0 and 1: \"1.5\"). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Template with unknown field",
+			args:          []string{"-fields", "name,template({{nickname}})"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: template({{nickname}}) (template: template:1: function \"nickname\" not defined). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Duplicate field",
+			args:          []string{"-fields", "name,age,name"},
+			expectedError: "Unable to generate CSV data. Duplicate fields selected: name",
+		},
+		{
+			name:          "Duplicate field in a different case",
+			args:          []string{"-fields", "name,NAME", "-keepfieldcase"},
+			expectedError: "Unable to generate CSV data. Duplicate fields selected: NAME",
+		},
+		{
+			name:          "Unknown transform",
+			args:          []string{"-fields", "name:shout"},
+			expectedError: "Unable to generate CSV data. Invalid fields selected: name:shout (unknown transform \"shout\", supported transforms are: lower, title, upper). Valid fields are: " + validFieldList,
+		},
+		{
+			name:          "Duplicate field with a transform",
+			args:          []string{"-fields", "name,name:upper"},
+			expectedError: "Unable to generate CSV data. Duplicate fields selected: name",
+		},
+		{
+			name:          "Multiple duplicate fields",
+			args:          []string{"-fields", "age,int(1,5),name,int(1,5),age,age"},
+			expectedError: "Unable to generate CSV data. Duplicate fields selected: int(1,5), age",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := run(tt.args)
+
+			if err == nil || err.Error() != tt.expectedError {
+				t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestRun_SuccessCases(t *testing.T) {
+	// Most cases write to the default output directory, which is only removed if the test
+	// created it.
+	_, statErr := os.Stat("output")
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+
+		if os.IsNotExist(statErr) {
+			os.RemoveAll("output")
+		}
+		os.RemoveAll("test_outdir")
+	}()
+
+	filenameDir, semicolonDir, tabDir := t.TempDir(), t.TempDir(), t.TempDir()
+
+	tests := []struct {
+		name             string
+		args             []string
+		expectedOut      string
+		outputDir        string
+		filename         string
+		delimiter        rune
+		expectedFileData [][]string
+	}{
+		{
+			name:             "Default values",
+			args:             []string{"-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"name", "age"}, {"Zion Brakus", "59"}},
+		},
+		{
+			name:             "Two rows",
+			args:             []string{"-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"name", "age"}, {"Zion Brakus", "59"}, {"Federico Prosacco", "66"}},
+		},
+		{
+			name:             "Two rows using alias",
+			args:             []string{"-n", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"name", "age"}, {"Zion Brakus", "59"}, {"Federico Prosacco", "66"}},
+		},
+		{
+			name:             "Fields under max fields",
+			args:             []string{"-fields", "name,age", "-maxfields", "3", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"name", "age"}, {"Zion Brakus", "59"}},
+		},
+		{
+			name:             "Mixed case fields",
+			args:             []string{"-fields", "Name,AGE", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"name", "age"}, {"Zion Brakus", "59"}},
+		},
+		{
+			name:             "Mixed case fields keeping their case",
+			args:             []string{"-fields", "Name,AGE", "-keepfieldcase", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"Name", "AGE"}, {"Zion Brakus", "59"}},
+		},
+		{
+			name:             "Padded ids",
+			args:             []string{"-fields", "id,name", "-rows", "2", "-idwidth", "6", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"id", "name"}, {"000001", "Zion Brakus"}, {"000002", "Federico Prosacco"}},
+		},
+		{
+			name:             "Ids wider than the id width",
+			args:             []string{"-fields", "id", "-rows", "10", "-idwidth", "1", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"id"}, {"1"}, {"2"}, {"3"}, {"4"}, {"5"}, {"6"}, {"7"}, {"8"}, {"9"}, {"10"}},
+		},
+		{
+			name:             "Transformed fields",
+			args:             []string{"-fields", "name:upper,email:title,lastName:lower", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"name", "email", "lastName"}, {"ZION BRAKUS", "Zion.brakus@productparadigms.biz", "brakus"}},
+		},
+		{
+			name:             "Custom fields",
+			args:             []string{"-fields", "email,firstName,lastName,city", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"email", "firstName", "lastName", "city"}, {"zion.brakus@productparadigms.biz", "Zion", "Brakus", "Omaha"}},
+		},
+		{
+			name:             "Address field containing commas",
+			args:             []string{"-fields", "address,name", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"address", "name"}, {"152 West Wayborough, Omaha, Alabama 11322", "Zion Brakus"}},
+		},
+		{
+			name:             "Zip field",
+			args:             []string{"-fields", "city,zip", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"city", "zip"}, {"Omaha", "11322"}},
+		},
+		{
+			name:             "Location fields share an address",
+			args:             []string{"-fields", "address,city,state,zip", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"address", "city", "state", "zip"}, {"152 West Wayborough, Omaha, Alabama 11322", "Omaha", "Alabama", "11322"}},
+		},
+		{
+			name:             "UUID field",
+			args:             []string{"-fields", "uuid,name", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"uuid", "name"}, {"735389a0-65e1-46ce-a063-4ef72a78aabf", "Zion Brakus"}, {"99c993a2-0abd-4bcb-adba-5c8dea2aebf4", "Federico Prosacco"}},
+		},
+		{
+			name:             "Company field",
+			args:             []string{"-fields", "company,name", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"company", "name"}, {"LegiStorm", "Zion Brakus"}, {"Municode", "Federico Prosacco"}},
+		},
+		{
+			name:             "Boolean fields",
+			args:             []string{"-fields", "active,bool", "-rows", "3", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"active", "bool"}, {"true", "true"}, {"true", "false"}, {"true", "true"}},
+		},
+		{
+			name:             "Custom boolean format",
+			args:             []string{"-fields", "active,bool", "-rows", "3", "-boolformat", "yes/no", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"active", "bool"}, {"yes", "yes"}, {"yes", "no"}, {"yes", "yes"}},
+		},
+		{
+			name:             "Coordinate fields",
+			args:             []string{"-fields", "latitude,longitude", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"latitude", "longitude"}, {"-42.059320", "-43.939807"}, {"35.289423", "9.489079"}},
+		},
+		{
+			name:             "Custom coordinate precision",
+			args:             []string{"-fields", "latitude,longitude", "-coordprecision", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"latitude", "longitude"}, {"-42.06", "-43.94"}},
+		},
+		{
+			name:             "Custom precision",
+			args:             []string{"-fields", "price,latitude,longitude,price(0.5,20)", "-precision", "3", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"price", "latitude", "longitude", "price(0.5,20)"}, {"460.720", "-42.059", "-43.940", "3.340"}},
+		},
+		{
+			name:             "Precision overrides coordinate precision",
+			args:             []string{"-fields", "price,latitude,longitude,price(0.5,20)", "-precision", "0", "-coordprecision", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"price", "latitude", "longitude", "price(0.5,20)"}, {"461", "-42", "-44", "3"}},
+		},
+		{
+			name:             "Integer range fields",
+			args:             []string{"-fields", "int(1,1000),name,int(5,5)", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"int(1,1000)", "name", "int(5,5)"}, {"509", "Zion Brakus", "5"}, {"586", "Federico Prosacco", "5"}},
+		},
+		{
+			name:             "Field with its own seed",
+			args:             []string{"-fields", "name,email@42", "-rows", "2", "-seed", "2"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"name", "email"}, {"Clarissa Zemlak", "ruthe.willms@corporateengineer.biz"}, {"Thaddeus Rempel", "chet.mann@internalstrategic.io"}},
+		},
+		{
+			name:             "Duplicate fields allowed",
+			args:             []string{"-fields", "name,age,name", "-allowduplicates", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"name", "age", "name"}, {"Zion Brakus", "59", "Zion Brakus"}, {"Federico Prosacco", "66", "Federico Prosacco"}},
+		},
+		{
+			name:             "Zero padded integer format",
+			args:             []string{"-fields", "age,int(1,5),id", "-intformat", "%03d", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"age", "int(1,5)", "id"}, {"059", "003", "1"}, {"068", "005", "2"}},
+		},
+		{
+			name:             "Suffixed integer format",
+			args:             []string{"-fields", "age", "-intformat", "%d years", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"age"}, {"59 years"}, {"66 years"}},
+		},
+		{
+			name:             "Price fields",
+			args:             []string{"-fields", "price,price(0.5,20)", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"price", "price(0.5,20)"}, {"460.72", "3.34"}, {"257.92", "0.97"}},
+		},
+		{
+			name:             "SSN field",
+			args:             []string{"-fields", "id,ssn", "-rows", "3", "-workers", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"id", "ssn"}, {"1", "997-51-2820"}, {"2", "935-97-4357"}, {"3", "974-43-5894"}},
+		},
+		{
+			name:             "Gender field",
+			args:             []string{"-fields", "gender,name", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"gender", "name"}, {"female", "Elizabeth Streich"}, {"male", "George Bogan"}},
+		},
+		{
+			name:             "Username field",
+			args:             []string{"-fields", "username,email", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"username", "email"}, {"zion.brakus", "zion.brakus@productparadigms.biz"}, {"federico.prosacco", "federico.prosacco@regionalintegrate.net"}},
+		},
+		{
+			name:             "Country consistent with address",
+			args:             []string{"-fields", "country,city", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"country", "city"}, {"United States", "Omaha"}, {"United States", "Pittsburgh"}},
+		},
+		{
+			name:             "Country without address fields",
+			args:             []string{"-fields", "country,name", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"country", "name"}, {"Lebanon", "Zion Brakus"}, {"Myanmar", "Federico Prosacco"}},
+		},
+		{
+			name:             "Explicit en-US locale",
+			args:             []string{"-locale", "en-US", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"name", "age"}, {"Zion Brakus", "59"}},
+		},
+		{
+			name:             "Email domain derived from company",
+			args:             []string{"-fields", "company,email", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"company", "email"}, {"T. Rowe Price", "zion.brakus@t-rowe-price.com"}, {"Verdafero", "federico.prosacco@verdafero.com"}},
+		},
+		{
+			name:             "MAC address field",
+			args:             []string{"-fields", "mac", "-rows", "3", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"mac"}, {"81:95:9c:f6:e5:9d"}, {"53:41:ea:a4:6e:80"}, {"ee:4e:f1:4f:42:c5"}},
+		},
+		{
+			name:             "Currency fields",
+			args:             []string{"-fields", "price,currency,currencyname", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"price", "currency", "currencyname"}, {"460.72", "FJD", "Fiji Dollar"}, {"146.56", "QAR", "Qatar Riyal"}},
+		},
+		{
+			name:        "Job fields",
+			args:        []string{"-fields", "company,jobTitle,jobDescriptor,jobLevel,job,email", "-rows", "2", "-seed", "1"},
+			expectedOut: "CSV file successfully generated at output/output.csv.",
+			filename:    "output.csv",
+			expectedFileData: [][]string{
+				{"company", "jobTitle", "jobDescriptor", "jobLevel", "job", "email"},
+				{"T. Rowe Price", "Strategist", "Dynamic", "Tactics", "Dynamic Tactics Strategist", "zion.brakus@t-rowe-price.com"},
+				{"BetterLesson", "Representative", "Central", "Assurance", "Central Assurance Representative", "chanel.mcclure@betterlesson.com"},
+			},
+		},
+		{
+			name:        "Template field",
+			args:        []string{"-fields", "id,template({{firstName}} <{{email}}>)", "-rows", "2", "-seed", "1"},
+			expectedOut: "CSV file successfully generated at output/output.csv.",
+			filename:    "output.csv",
+			expectedFileData: [][]string{
+				{"id", "template({{firstName}} <{{email}}>)"},
+				{"1", "Zion <zion.brakus@productparadigms.biz>"},
+				{"2", "Federico <federico.prosacco@regionalintegrate.net>"},
+			},
+		},
+		{
+			name:             "Animal fields",
+			args:             []string{"-fields", "animal,animaltype,petname", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"animal", "animaltype", "petname"}, {"lion", "invertebrates", "Nugget"}, {"woodchuck", "reptiles", "Nugget"}},
+		},
+		{
+			name:        "Food fields",
+			args:        []string{"-fields", "food,fruit,vegetable,dessert", "-rows", "2", "-seed", "1"},
+			expectedOut: "CSV file successfully generated at output/output.csv.",
+			filename:    "output.csv",
+			expectedFileData: [][]string{
+				{"food", "fruit", "vegetable", "dessert"},
+				{"Apple butterflies", "Cantaloupe", "Watercress", "Amish cream pie"},
+				{"Not your ordinary chocolate chip cookies liqueur laced", "Durian", "Swiss Chard", "Apricot banana squares"},
+			},
+		},
+		{
+			name:             "Emoji field",
+			args:             []string{"-fields", "id,emoji", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"id", "emoji"}, {"1", "🕣"}, {"2", "👓"}},
+		},
+		{
+			name:             "Hobby field",
+			args:             []string{"-fields", "id,hobby", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"id", "hobby"}, {"1", "Polo"}, {"2", "Trade fair visiting"}},
+		},
+		{
+			name:             "JSON blob field",
+			args:             []string{"-fields", "id,jsonblob", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"id", "jsonblob"}, {"1", `{"count":51,"enabled":true,"tag":"whale"}`}, {"2", `{"count":91,"enabled":true,"tag":"eye"}`}},
+		},
+		{
+			name:             "Department field",
+			args:             []string{"-fields", "id,department", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"id", "department"}, {"1", "Legal"}, {"2", "Marketing"}},
+		},
+		{
+			name:             "Credit card field",
+			args:             []string{"-fields", "id,creditcard", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"id", "creditcard"}, {"1", "1800996329645939332"}, {"2", "59936041965158"}},
+		},
+		{
+			name:             "HTTP status field",
+			args:             []string{"-fields", "id,httpstatus", "-rows", "3", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"id", "httpstatus"}, {"1", "200"}, {"2", "401"}, {"3", "200"}},
+		},
+		{
+			name:             "Progress field",
+			args:             []string{"-fields", "id,progress", "-rows", "4", "-workers", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"id", "progress"}, {"1", "25.00%"}, {"2", "50.00%"}, {"3", "75.00%"}, {"4", "100.00%"}},
+		},
+		{
+			name:             "Language fields",
+			args:             []string{"-fields", "language,languageabbr", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"language", "languageabbr"}, {"Latin", "mn"}, {"Nauru", "xh"}},
+		},
+		{
+			name:             "Time zone fields",
+			args:             []string{"-fields", "timezone,timezoneabbr", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"timezone", "timezoneabbr"}, {"Turkey Standard Time", "SAMT"}, {"Azerbaijan Standard Time", "MST"}},
+		},
+		{
+			name:             "Time zone fields with an address",
+			args:             []string{"-fields", "state,timezone,timezoneabbr", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"state", "timezone", "timezoneabbr"}, {"Alabama", "Central Standard Time", "CST"}, {"Hawaii", "Hawaiian Standard Time", "HST"}},
+		},
+		{
+			name:             "Salary fields",
+			args:             []string{"-fields", "salary,salary(40000,60000)", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"salary", "salary(40000,60000)"}, {"66001", "40424"}, {"41244", "40012"}},
+		},
+		{
+			name:             "Password fields",
+			args:             []string{"-fields", "password,passwordhash", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"password", "passwordhash"}, {"rkPs@LPR-L3O", "5b82c3f89a5b21bdf91b8b07a03c0ec1e2ec68d8047d6bed6475190ba439d0ec"}, {"SsU90xv59CSc", "4f65dc0f9559eefe5099b7d1028d0839e58cac4f251754c3542307dd2e3db5ee"}},
+		},
+		{
+			name:             "Shuffled columns",
+			args:             []string{"-fields", "id,name,age,city", "-rows", "2", "-seed", "1", "-shufflecolumns"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"name", "age", "id", "city"}, {"Zion Brakus", "59", "1", "Omaha"}, {"Federico Prosacco", "66", "2", "Pittsburgh"}},
+		},
+		{
+			name:             "Email prefix",
+			args:             []string{"-fields", "email,username", "-rows", "2", "-seed", "1", "-emailprefix", "test+"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"email", "username"}, {"test+zion.brakus@productparadigms.biz", "zion.brakus"}, {"test+federico.prosacco@regionalintegrate.net", "federico.prosacco"}},
+		},
+		{
+			name:             "Color fields",
+			args:             []string{"-fields", "color,hexcolor", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"color", "hexcolor"}, {"LightSalmon", "#29d64f"}, {"FireBrick", "#a6936c"}},
+		},
+		{
+			name:             "Sequential ID field",
+			args:             []string{"-fields", "id,name", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"id", "name"}, {"1", "Zion Brakus"}, {"2", "Federico Prosacco"}},
+		},
+		{
+			name:             "Text fields",
+			args:             []string{"-fields", "sentence(3),paragraph(1),name", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"sentence(3)", "paragraph(1)", "name"}, {"Bunch certain talk.", "Not kindness gang under tonight darkness to actor hey shake.", "Zion Brakus"}, {"Day week gold.", "My adventurous finally gain nobody constantly problem promptly cook up.", "Federico Prosacco"}},
+		},
+		{
+			name:             "URL field",
+			args:             []string{"-fields", "url", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"url"}, {"http://www.dynamicreinvent.io/one-to-one/real-time/systems/networks"}, {"https://www.dynamicrepurpose.org/cross-platform/global/relationships/synergize"}},
+		},
+		{
+			name:             "URL derived from company",
+			args:             []string{"-fields", "company,url,email", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"company", "url", "email"}, {"T. Rowe Price", "https://www.t-rowe-price.com", "zion.brakus@t-rowe-price.com"}, {"Verdafero", "https://www.verdafero.com", "federico.prosacco@verdafero.com"}},
+		},
+		{
+			name:             "Enum fields",
+			args:             []string{"-fields", "enum(active:70,inactive:20,pending:10),enum(a,b)", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"enum(active:70,inactive:20,pending:10)", "enum(a,b)"}, {"active", "b"}, {"active", "b"}},
+		},
+		{
+			name:             "Timestamp range fields",
+			args:             []string{"-fields", "timestamp(2020-01-01,2024-12-31),timestamp(2024-06-01T12:00:00Z,2024-06-01T12:00:00Z)", "-rows", "2", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at output/output.csv.",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"timestamp(2020-01-01,2024-12-31)", "timestamp(2024-06-01T12:00:00Z,2024-06-01T12:00:00Z)"}, {"2022-07-17T04:24:54Z", "2024-06-01T12:00:00Z"}, {"2022-12-05T17:53:49Z", "2024-06-01T12:00:00Z"}},
+		},
+		{
+			name:             "Custom file name",
+			args:             []string{"-outdir", filenameDir, "-filename", "test_data.csv", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at " + filenameDir + "/test_data.csv.",
+			outputDir:        filenameDir,
+			filename:         "test_data.csv",
+			expectedFileData: [][]string{{"name", "age"}, {"Zion Brakus", "59"}},
+		},
+		{
+			name:             "Custom output directory",
+			args:             []string{"-outdir", "test_outdir", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at test_outdir/output.csv.",
+			outputDir:        "test_outdir",
+			filename:         "output.csv",
+			expectedFileData: [][]string{{"name", "age"}, {"Zion Brakus", "59"}},
+		},
+		{
+			name:             "Semicolon delimiter",
+			args:             []string{"-outdir", semicolonDir, "-delimiter", ";", "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at " + semicolonDir + "/output.csv.",
+			outputDir:        semicolonDir,
+			filename:         "output.csv",
+			delimiter:        ';',
+			expectedFileData: [][]string{{"name", "age"}, {"Zion Brakus", "59"}},
+		},
+		{
+			name:             "Tab delimiter",
+			args:             []string{"-outdir", tabDir, "-delimiter", `\t`, "-seed", "1"},
+			expectedOut:      "CSV file successfully generated at " + tabDir + "/output.csv.",
+			outputDir:        tabDir,
+			filename:         "output.csv",
+			delimiter:        '\t',
+			expectedFileData: [][]string{{"name", "age"}, {"Zion Brakus", "59"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, w, _ := os.Pipe()
+			os.Stderr = w
+
+			if err := run(tt.args); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			w.Close()
+			var buf bytes.Buffer
+			io.Copy(&buf, r)
+
+			actualOut := buf.String()
+			lines := strings.Split(actualOut, "\n")
+			output := lines[5]
+
+			if output != tt.expectedOut {
+				t.Errorf("\nExpected output:\n%s\nGot:\n%s", tt.expectedOut, output)
+			}
+
+			// Read and verify the output file
+			outputDir := tt.outputDir
+			if outputDir == "" {
+				outputDir = "output"
+			}
+			outputFile, err := os.Open(fmt.Sprintf("%s/%s", outputDir, tt.filename))
+			if err != nil {
+				t.Errorf("Failed to open output file: %v", err)
+			}
+			defer outputFile.Close()
+
+			reader := csv.NewReader(outputFile)
+			if tt.delimiter != 0 {
+				reader.Comma = tt.delimiter
+			}
+			records, err := reader.ReadAll()
+			if err != nil {
+				t.Errorf("Failed to read CSV file: %v", err)
+			}
+
+			for idx, record := range records {
+				if len(record) != len(tt.expectedFileData[idx]) {
+					t.Errorf("\nColumn count mismatch at index %d.\nExpected: %d\nGot: %d", idx, len(tt.expectedFileData[idx]), len(record))
+				}
+
+				expectedRow := strings.Join(tt.expectedFileData[idx], ",")
+				actualRow := strings.Join(record, ",")
+				if actualRow != expectedRow {
+					t.Errorf("\nFile data mistmatch at index %d.\nExpected:\n%s\nGot:\n%s", idx, expectedRow, actualRow)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_StdoutOutput(t *testing.T) {
+	origStdout := os.Stdout
+	origStderr := os.Stderr
+	defer func() {
+		os.Stdout = origStdout
+		os.Stderr = origStderr
+	}()
+
+	stdoutR, stdoutW, _ := os.Pipe()
+	stderrR, stderrW, _ := os.Pipe()
+	os.Stdout = stdoutW
+	os.Stderr = stderrW
+
+	if err := run([]string{"-filename", "-", "-seed", "1"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	stdoutW.Close()
+	stderrW.Close()
+	var stdoutBuf, stderrBuf bytes.Buffer
+	io.Copy(&stdoutBuf, stdoutR)
+	io.Copy(&stderrBuf, stderrR)
+
+	expectedData := "name,age\nZion Brakus,59\n"
+	if stdoutBuf.String() != expectedData {
+		t.Errorf("\nExpected stdout:\n%s\nGot:\n%s", expectedData, stdoutBuf.String())
+	}
+
+	expectedOut := "CSV data successfully written to stdout."
+	if !strings.Contains(stderrBuf.String(), expectedOut) {
+		t.Errorf("\nExpected stderr to contain:\n%s\nGot:\n%s", expectedOut, stderrBuf.String())
+	}
+
+	if _, err := os.Stat("output/-"); err == nil {
+		t.Errorf("Expected no file to be created when writing to stdout")
+	}
+}
+
+func TestRun_Version(t *testing.T) {
+	origStdout := os.Stdout
+	defer func() {
+		os.Stdout = origStdout
+	}()
+
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	// Invalid generation flags are ignored, nothing is generated when printing the version.
+	outputDir := filepath.Join(t.TempDir(), "version")
+	err := run([]string{"-version", "-rows", "0", "-outdir", outputDir})
+	w.Close()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+
+	output := strings.TrimSpace(buf.String())
+	if output != versionString() {
+		t.Errorf("\nExpected output:\n%s\nGot:\n%s", versionString(), output)
+	}
+
+	expectedPrefix := "go-test-csv-generator dev (commit "
+	if !strings.HasPrefix(output, expectedPrefix) || !strings.Contains(output, runtime.Version()) {
+		t.Errorf("Expected version string starting with %q and containing %q, got %q", expectedPrefix, runtime.Version(), output)
+	}
+
+	if _, err := os.Stat(outputDir); err == nil {
+		t.Errorf("Expected no output directory to be created when printing the version")
+	}
+}
+
+func TestRun_Seed(t *testing.T) {
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+	}()
+
+	// runWithSeed generates a file with the given seed arguments and returns the seed
+	// that was printed along with the generated file contents.
+	outputDir := t.TempDir()
+	runWithSeed := func(args ...string) (string, string) {
+		r, w, _ := os.Pipe()
+		os.Stderr = w
+
+		err := run(append([]string{"-rows", "5", "-outdir", outputDir, "-filename", "seed.csv"}, args...))
+		w.Close()
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		seedLine := strings.Split(buf.String(), "\n")[3]
+
+		data, err := os.ReadFile(filepath.Join(outputDir, "seed.csv"))
+		if err != nil {
+			t.Fatalf("Failed to read output file: %v", err)
+		}
+
+		return seedLine, string(data)
+	}
+
+	t.Run("Fixed seed", func(t *testing.T) {
+		firstSeed, firstData := runWithSeed("-seed", "1")
+		secondSeed, secondData := runWithSeed("-seed", "1")
+
+		if firstSeed != "Seed: 1" || secondSeed != "Seed: 1" {
+			t.Errorf("Expected seed line %q, got %q and %q", "Seed: 1", firstSeed, secondSeed)
+		}
+
+		if firstData != secondData {
+			t.Errorf("\nExpected identical data for a fixed seed.\nFirst:\n%s\nSecond:\n%s", firstData, secondData)
+		}
+	})
+
+	t.Run("Random seed", func(t *testing.T) {
+		firstSeed, firstData := runWithSeed()
+		secondSeed, secondData := runWithSeed()
+
+		if firstSeed == "Seed: 0" || firstSeed == secondSeed {
+			t.Errorf("Expected two different random seeds, got %q and %q", firstSeed, secondSeed)
+		}
+
+		if firstData == secondData {
+			t.Errorf("Expected different data for random seeds, got:\n%s", firstData)
+		}
+
+		// The printed seed reproduces the random run.
+		_, reproducedData := runWithSeed("-seed", strings.TrimPrefix(firstSeed, "Seed: "))
+		if reproducedData != firstData {
+			t.Errorf("\nExpected printed seed to reproduce the run.\nExpected:\n%s\nGot:\n%s", firstData, reproducedData)
+		}
+	})
+}
+
+func TestRun_Gzip(t *testing.T) {
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+	}()
+
+	tests := []struct {
+		name         string
+		filename     string
+		expectedFile string
+	}{
+		{
+			name:         "Appends .gz to the filename",
+			filename:     "gzip.csv",
+			expectedFile: "gzip.csv.gz",
+		},
+		{
+			name:         "Keeps an existing .gz extension",
+			filename:     "gzip_ext.csv.gz",
+			expectedFile: "gzip_ext.csv.gz",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, w, _ := os.Pipe()
+			os.Stderr = w
+
+			outputDir := t.TempDir()
+			err := run([]string{"-gzip", "-rows", "2", "-outdir", outputDir, "-filename", tt.filename, "-seed", "1"})
+			w.Close()
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			outputFile, err := os.Open(filepath.Join(outputDir, tt.expectedFile))
+			if err != nil {
+				t.Fatalf("Failed to open output file: %v", err)
+			}
+			defer outputFile.Close()
+
+			gzipReader, err := gzip.NewReader(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read gzip header: %v", err)
+			}
+
+			data, err := io.ReadAll(gzipReader)
+			if err != nil {
+				t.Fatalf("Failed to decompress output file: %v", err)
+			}
+
+			expectedData := "name,age\nZion Brakus,59\nFederico Prosacco,66\n"
+			if string(data) != expectedData {
+				t.Errorf("\nExpected file data:\n%s\nGot:\n%s", expectedData, data)
+			}
+		})
+	}
+}
+
+func TestRun_Progress(t *testing.T) {
+	origStdout := os.Stdout
+	origStderr := os.Stderr
+	defer func() {
+		os.Stdout = origStdout
+		os.Stderr = origStderr
+	}()
+
+	tests := []struct {
+		name          string
+		args          []string
+		expectedLines []string
+	}{
+		{
+			name: "Progress every 10,000 rows",
+			args: []string{"-rows", "25000", "-filename", "progress.csv", "-seed", "1"},
+			expectedLines: []string{
+				"Wrote 10000 of 25000 rows (40.0%",
+				"Wrote 20000 of 25000 rows (80.0%",
+			},
+		},
+		{
+			name:          "Quiet",
+			args:          []string{"-rows", "25000", "-filename", "progress.csv", "-seed", "1", "-quiet"},
+			expectedLines: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, stdoutW, _ := os.Pipe()
+			stderrR, stderrW, _ := os.Pipe()
+			os.Stdout = stdoutW
+			os.Stderr = stderrW
+
+			err := run(append(tt.args, "-outdir", t.TempDir()))
+			stdoutW.Close()
+			stderrW.Close()
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			var buf bytes.Buffer
+			io.Copy(&buf, stderrR)
+			// Progress lines are interleaved with the other informational messages.
+			lines := []string{}
+			for _, line := range strings.Split(buf.String(), "\n") {
+				if strings.HasPrefix(line, "Wrote ") {
+					lines = append(lines, line)
+				}
+			}
+
+			if len(lines) != len(tt.expectedLines) {
+				t.Fatalf("Expected %d progress lines, got %d:\n%s", len(tt.expectedLines), len(lines), buf.String())
+			}
+
+			for idx, expectedLine := range tt.expectedLines {
+				if !strings.HasPrefix(lines[idx], expectedLine) {
+					t.Errorf("\nExpected progress line to start with:\n%s\nGot:\n%s", expectedLine, lines[idx])
+				}
+			}
+		})
+	}
+}
+
+func TestRun_Append(t *testing.T) {
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+	}()
+
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer w.Close()
+
+	outputDir := t.TempDir()
+	args := []string{"-append", "-outdir", outputDir, "-filename", "append.csv", "-seed", "1"}
+
+	// The first run creates the file with a header, the second only appends rows.
+	for i := 0; i < 2; i++ {
+		if err := run(args); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "append.csv"))
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+
+	expectedData := "name,age\nZion Brakus,59\nZion Brakus,59\n"
+	if string(data) != expectedData {
+		t.Errorf("\nExpected file data:\n%s\nGot:\n%s", expectedData, data)
+	}
+}
+
+func TestRun_Overwrite(t *testing.T) {
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+	}()
+
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer w.Close()
+
+	tests := []struct {
+		name          string
+		overwrite     string
+		expectedData  string
+		expectedError string
+	}{
+		{
+			name:         "Overwriting enabled",
+			overwrite:    "-overwrite=true",
+			expectedData: "name,age\nZion Brakus,59\n",
+		},
+		{
+			name:          "Overwriting disabled",
+			overwrite:     "-overwrite=false",
+			expectedData:  "existing data\n",
+			expectedError: "Failed to generate CSV data: %s already exists and overwriting is disabled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			filePath := filepath.Join(outputDir, "output.csv")
+			if err := os.WriteFile(filePath, []byte("existing data\n"), 0o644); err != nil {
+				t.Fatalf("Failed to write existing file: %v", err)
+			}
+
+			err := run([]string{tt.overwrite, "-outdir", outputDir, "-seed", "1"})
+			if tt.expectedError == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+			} else if expectedError := fmt.Sprintf(tt.expectedError, filePath); err == nil || err.Error() != expectedError {
+				t.Errorf("Expected error: %v\nGot: %v", expectedError, err)
+			}
+
+			data, err := os.ReadFile(filePath)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+
+			if string(data) != tt.expectedData {
+				t.Errorf("\nExpected file data:\n%s\nGot:\n%s", tt.expectedData, data)
+			}
+		})
+	}
+}
+
+func TestRun_AlwaysQuote(t *testing.T) {
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+	}()
+
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer w.Close()
+
+	tests := []struct {
+		name         string
+		args         []string
+		expectedData string
+	}{
+		{
+			name:         "Minimal quoting by default",
+			args:         []string{},
+			expectedData: "name,address\nZion Brakus,\"152 West Wayborough, Omaha, Alabama 11322\"\n",
+		},
+		{
+			name:         "Always quote",
+			args:         []string{"-alwaysquote"},
+			expectedData: "\"name\",\"address\"\n\"Zion Brakus\",\"152 West Wayborough, Omaha, Alabama 11322\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			args := append([]string{"-fields", "name,address", "-outdir", outputDir, "-seed", "1"}, tt.args...)
+			if err := run(args); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			data, err := os.ReadFile(filepath.Join(outputDir, "output.csv"))
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+
+			if string(data) != tt.expectedData {
+				t.Errorf("\nExpected file data:\n%s\nGot:\n%s", tt.expectedData, data)
+			}
+		})
+	}
+}
+
+func TestRun_CRLF(t *testing.T) {
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+	}()
+
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer w.Close()
+
+	tests := []struct {
+		name         string
+		args         []string
+		expectedCRLF bool
+	}{
+		{name: "LF by default", args: []string{}, expectedCRLF: false},
+		{name: "CRLF", args: []string{"-crlf"}, expectedCRLF: true},
+		{name: "LF when always quoting", args: []string{"-alwaysquote"}, expectedCRLF: false},
+		{name: "CRLF when always quoting", args: []string{"-crlf", "-alwaysquote"}, expectedCRLF: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			args := append([]string{"-rows", "3", "-outdir", outputDir, "-seed", "1"}, tt.args...)
+			if err := run(args); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			data, err := os.ReadFile(filepath.Join(outputDir, "output.csv"))
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+
+			crlfCount := bytes.Count(data, []byte("\r\n"))
+			lfCount := bytes.Count(data, []byte("\n"))
+			if lfCount != 4 {
+				t.Fatalf("Expected 4 lines, got %d:\n%q", lfCount, data)
+			}
+
+			if tt.expectedCRLF && crlfCount != lfCount {
+				t.Errorf("Expected every line to end with \\r\\n, got %q", data)
+			}
+			if !tt.expectedCRLF && crlfCount != 0 {
+				t.Errorf("Expected no \\r\\n line endings, got %q", data)
+			}
+		})
+	}
+}
+
+func TestRun_BOM(t *testing.T) {
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+	}()
+
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer w.Close()
+
+	bom := []byte("\xEF\xBB\xBF")
+	tests := []struct {
+		name        string
+		args        []string
+		runs        int
+		expectedBOM bool
+	}{
+		{name: "No BOM by default", args: []string{}, runs: 1, expectedBOM: false},
+		{name: "BOM", args: []string{"-bom"}, runs: 1, expectedBOM: true},
+		{name: "BOM when always quoting", args: []string{"-bom", "-alwaysquote"}, runs: 1, expectedBOM: true},
+		{name: "Single BOM when appending", args: []string{"-bom", "-append"}, runs: 2, expectedBOM: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			args := append([]string{"-rows", "3", "-fields", "name,emoji", "-outdir", outputDir, "-seed", "1"}, tt.args...)
+			for i := 0; i < tt.runs; i++ {
+				if err := run(args); err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+			}
+
+			data, err := os.ReadFile(filepath.Join(outputDir, "output.csv"))
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+
+			if hasBOM := bytes.HasPrefix(data, bom); hasBOM != tt.expectedBOM {
+				t.Errorf("Expected BOM to be %v, got %v: %q", tt.expectedBOM, hasBOM, data)
+			}
+			if count := bytes.Count(data, bom); count > 1 {
+				t.Errorf("Expected at most one BOM, got %d: %q", count, data)
+			}
+
+			// The header follows the BOM, so it's still the first line.
+			if header := bytes.TrimPrefix(data, bom); !bytes.HasPrefix(header, []byte("name,emoji\n")) && !bytes.HasPrefix(header, []byte(`"name","emoji"`)) {
+				t.Errorf("Expected the header to start the file, got %q", data)
+			}
+		})
+	}
+}
+
+func TestRun_Comment(t *testing.T) {
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+	}()
+
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer w.Close()
+
+	comment := "# generated by go-test-csv-generator seed=1"
+	tests := []struct {
+		name            string
+		args            []string
+		runs            int
+		expectedComment bool
+	}{
+		{name: "No comment by default", args: []string{}, runs: 1, expectedComment: false},
+		{name: "Comment", args: []string{"-comment"}, runs: 1, expectedComment: true},
+		{name: "Comment after the BOM", args: []string{"-comment", "-bom"}, runs: 1, expectedComment: true},
+		{name: "Single comment when appending", args: []string{"-comment", "-append"}, runs: 2, expectedComment: true},
+		{name: "Verified comment", args: []string{"-comment", "-verify"}, runs: 1, expectedComment: true},
+		{name: "Verified comment after the BOM", args: []string{"-comment", "-bom", "-verify"}, runs: 1, expectedComment: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			args := append([]string{"-rows", "3", "-fields", "name,age", "-outdir", outputDir, "-seed", "1"}, tt.args...)
+			for i := 0; i < tt.runs; i++ {
+				if err := run(args); err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+			}
+
+			data, err := os.ReadFile(filepath.Join(outputDir, "output.csv"))
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+
+			data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+			lines := strings.Split(string(data), "\n")
+			if hasComment := lines[0] == comment; hasComment != tt.expectedComment {
+				t.Errorf("Expected the comment line to be %v, got %v: %q", tt.expectedComment, hasComment, data)
+			}
+			if count := strings.Count(string(data), comment); count > 1 {
+				t.Errorf("Expected at most one comment line, got %d: %q", count, data)
+			}
+
+			// A CSV reader set to skip comments still reads the header and every row, once
+			// any byte order mark is removed.
+			reader := csv.NewReader(bytes.NewReader(data))
+			reader.Comment = '#'
+			records, err := reader.ReadAll()
+			if err != nil {
+				t.Fatalf("Failed to read CSV data: %v", err)
+			}
+			if records[0][0] != "name" || len(records) != 1+3*tt.runs {
+				t.Errorf("Expected a header and %d rows, got %q", 3*tt.runs, records)
+			}
+		})
+	}
 }
 
-func (d MockDataGenerator) generateCsvData(rows int, fields string, outputDir string, filename string, fileHandler FileHandler, csvWriter FileWriter) error {
-	if d.ShouldFail {
-		return fmt.Errorf("generateCsvData failed")
+func TestRun_Interrupt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Interrupts can't be sent to a process on Windows")
 	}
 
-	return nil
-}
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+	}()
 
-type MockFileHandler struct {
-	ShouldFailMkDirAll bool
-	ShouldFailCreate   bool
-}
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer w.Close()
+
+	// The output file is only created once run is listening for the signal.
+	outputDir := t.TempDir()
+	go func() {
+		for {
+			if entries, _ := os.ReadDir(outputDir); len(entries) > 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		process, _ := os.FindProcess(os.Getpid())
+		process.Signal(os.Interrupt)
+	}()
+
+	err := run([]string{"-rows", "1000000000", "-fields", "id", "-outdir", outputDir, "-quiet", "-seed", "1"})
+	match := regexp.MustCompile(`^Failed to generate CSV data: generation cancelled after (\d+) rows: context canceled$`).FindStringSubmatch(fmt.Sprint(err))
+	if match == nil {
+		t.Fatalf("Expected the generation to be cancelled, got: %v", err)
+	}
 
-func (f MockFileHandler) MkDirAll(path string, perm os.FileMode) error {
-	if f.ShouldFailMkDirAll {
-		return fmt.Errorf("MkDirAll failed")
+	data, err := os.ReadFile(filepath.Join(outputDir, "output.csv"))
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
 	}
 
-	return nil
+	// Every row written before the interrupt is flushed, ending with a complete row.
+	lines := strings.Split(string(data), "\n")
+	if lines[0] != "id" || lines[len(lines)-1] != "" || fmt.Sprint(len(lines)-2) != match[1] {
+		t.Fatalf("Expected a header and %s complete rows, got %d lines ending with %q", match[1], len(lines), lines[len(lines)-1])
+	}
+	for i, line := range lines[1 : len(lines)-1] {
+		if line != fmt.Sprint(i+1) {
+			t.Fatalf("Expected row %d to be %d, got %q", i+1, i+1, line)
+		}
+	}
 }
 
-func (f MockFileHandler) Create(name string) (*os.File, error) {
-	if f.ShouldFailCreate {
-		return nil, fmt.Errorf("Create failed")
+func TestRun_Report(t *testing.T) {
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+	}()
+
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer w.Close()
+
+	outputDir := t.TempDir()
+	reportPath := filepath.Join(outputDir, "report.json")
+	err := run([]string{"-rows", "3", "-fields", "name,int(1,10)", "-outdir", outputDir, "-filename", "report.csv", "-seed", "7", "-report", reportPath})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
 	}
 
-	return nil, nil
-}
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
 
-type MockFileWriter struct {
-	ShouldFail bool
-}
+	var report csvgen.Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Failed to parse report: %v", err)
+	}
 
-func (w MockFileWriter) Write(row []string, writer *csv.Writer) error {
-	if w.ShouldFail {
-		return fmt.Errorf("Write failed")
+	outputPath := filepath.Join(outputDir, "report.csv")
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to stat output file: %v", err)
 	}
 
-	return nil
+	if report.Rows != 3 {
+		t.Errorf("Expected 3 rows, got %d", report.Rows)
+	}
+	if !slices.Equal(report.Fields, []string{"name", "int(1,10)"}) {
+		t.Errorf("Expected fields [name int(1,10)], got %v", report.Fields)
+	}
+	if report.Seed != 7 {
+		t.Errorf("Expected seed 7, got %d", report.Seed)
+	}
+	if report.Output != outputPath {
+		t.Errorf("Expected output %q, got %q", outputPath, report.Output)
+	}
+	if report.Bytes != info.Size() {
+		t.Errorf("Expected %d bytes, got %d", info.Size(), report.Bytes)
+	}
+	if report.ElapsedSeconds <= 0 {
+		t.Errorf("Expected a positive elapsed time, got %v", report.ElapsedSeconds)
+	}
 }
 
-func TestMain_ErrorCases(t *testing.T) {
-	origArgs := os.Args
+func TestRun_Manifest(t *testing.T) {
+	origStderr := os.Stderr
 	defer func() {
-		os.Args = origArgs
+		os.Stderr = origStderr
 	}()
 
-	tests := []struct {
-		name          string
-		args          []string
-		expectedError string
-	}{
-		{
-			name:          "Less than 1 row",
-			args:          []string{"cmd", "-rows", "0"},
-			expectedError: "Invalid flags: invalid number of rows: 0",
-		},
-		{
-			name:          "No fields",
-			args:          []string{"cmd", "-fields", ""},
-			expectedError: "Invalid flags: fields cannot be empty",
-		},
-		{
-			name:          "No output file name",
-			args:          []string{"cmd", "-filename", ""},
-			expectedError: "Invalid flags: filename cannot be empty",
-		},
-		{
-			name:          "Invalid fields",
-			args:          []string{"cmd", "-fields", "invalid"},
-			expectedError: "Unable to generate CSV data. Invalid fields selected: invalid",
-		},
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer w.Close()
+
+	readManifest := func(t *testing.T, path string) csvgen.Manifest {
+		t.Helper()
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read manifest: %v", err)
+		}
+
+		var manifest csvgen.Manifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			t.Fatalf("Failed to parse manifest: %v", err)
+		}
+		return manifest
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	t.Run("Single file", func(t *testing.T) {
+		outputDir := t.TempDir()
+		manifestPath := filepath.Join(outputDir, "manifest.json")
+		if err := run([]string{"-rows", "3", "-outdir", outputDir, "-gzip", "-seed", "1", "-manifest", manifestPath}); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
 
-			os.Args = tt.args
+		expected := []csvgen.ManifestFile{{Path: filepath.Join(outputDir, "output.csv.gz"), Rows: 3}}
+		if manifest := readManifest(t, manifestPath); !slices.Equal(manifest.Files, expected) {
+			t.Errorf("Expected files %v, got %v", expected, manifest.Files)
+		}
+	})
 
-			defer func() {
-				if err := recover(); err != nil {
-					if err != tt.expectedError {
-						t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
-					}
-				}
-			}()
+	t.Run("Tables", func(t *testing.T) {
+		outputDir := t.TempDir()
+		schemaPath := filepath.Join(outputDir, "schema.json")
+		schema := `{"tables": [{"name": "users", "rows": 2, "fields": "id,name"}, {"name": "orders", "rows": 5, "fields": "id", "foreignKeys": [{"column": "userId", "references": "users"}]}]}`
+		if err := os.WriteFile(schemaPath, []byte(schema), 0o644); err != nil {
+			t.Fatalf("Failed to write schema file: %v", err)
+		}
 
-			main()
-		})
-	}
+		manifestPath := filepath.Join(outputDir, "manifest.json")
+		if err := run([]string{"-schema", schemaPath, "-outdir", outputDir, "-seed", "1", "-manifest", manifestPath}); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		expected := []csvgen.ManifestFile{
+			{Path: filepath.Join(outputDir, "users.csv"), Rows: 2},
+			{Path: filepath.Join(outputDir, "orders.csv"), Rows: 5},
+		}
+		if manifest := readManifest(t, manifestPath); !slices.Equal(manifest.Files, expected) {
+			t.Errorf("Expected files %v, got %v", expected, manifest.Files)
+		}
+
+		for _, file := range expected {
+			if _, err := os.Stat(file.Path); err != nil {
+				t.Errorf("Expected %s to be written: %v", file.Path, err)
+			}
+		}
+	})
 }
 
-func TestMain_SuccessCases(t *testing.T) {
+func TestRun_DryRun(t *testing.T) {
 	origStdout := os.Stdout
-	origArgs := os.Args
 	defer func() {
 		os.Stdout = origStdout
-		os.Args = origArgs
-
-		// os.RemoveAll("output")
 	}()
 
 	tests := []struct {
-		name             string
-		args             []string
-		expectedOut      string
-		filename         string
-		expectedFileData [][]string
+		name         string
+		args         []string
+		expectedData string
 	}{
 		{
-			name:             "Default values",
-			args:             []string{"cmd", "-seed", "1"},
-			expectedOut:      "CSV file successfully generated at output/output.csv.",
-			filename:         "output.csv",
-			expectedFileData: [][]string{{"name", "age"}, {"Zion Brakus", "94"}},
-		},
-		{
-			name:             "Two rows",
-			args:             []string{"cmd", "-rows", "2", "-seed", "1"},
-			expectedOut:      "CSV file successfully generated at output/output.csv.",
-			filename:         "output.csv",
-			expectedFileData: [][]string{{"name", "age"}, {"Zion Brakus", "94"}, {"Randy Braun", "98"}},
+			name:         "CSV",
+			args:         []string{"-fields", "name,address"},
+			expectedData: "name,address\nZion Brakus,\"152 West Wayborough, Omaha, Alabama 11322\"\n",
 		},
 		{
-			name:             "Custom fields",
-			args:             []string{"cmd", "-fields", "email,firstName,lastName,city", "-seed", "1"},
-			expectedOut:      "CSV file successfully generated at output/output.csv.",
-			filename:         "output.csv",
-			expectedFileData: [][]string{{"email", "firstName", "lastName", "city"}, {"zion.brakus@productparadigms.biz", "Zion", "Brakus", "Irving"}},
+			name:         "JSON",
+			args:         []string{"-fields", "name,address", "-format", "json"},
+			expectedData: `{"name":"Zion Brakus","address":"152 West Wayborough, Omaha, Alabama 11322"}` + "\n",
 		},
 		{
-			name:             "Custom file name",
-			args:             []string{"cmd", "-filename", "test_data.csv", "-seed", "1"},
-			expectedOut:      "CSV file successfully generated at output/test_data.csv.",
-			filename:         "test_data.csv",
-			expectedFileData: [][]string{{"name", "age"}, {"Zion Brakus", "94"}},
+			name:         "Only one sample row",
+			args:         []string{"-rows", "1000", "-delimiter", ";"},
+			expectedData: "name;age\nZion Brakus;59\n",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
-
-			os.Args = tt.args
-
 			r, w, _ := os.Pipe()
 			os.Stdout = w
 
-			main()
-
+			outputDir := filepath.Join(t.TempDir(), "dryrun")
+			err := run(append([]string{"-dryrun", "-outdir", outputDir, "-seed", "1"}, tt.args...))
 			w.Close()
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
 			var buf bytes.Buffer
 			io.Copy(&buf, r)
 
-			actualOut := buf.String()
-			lines := strings.Split(actualOut, "\n")
-			output := lines[4]
-
-			if output != tt.expectedOut {
-				t.Errorf("\nExpected output:\n%s\nGot:\n%s", tt.expectedOut, output)
+			if buf.String() != tt.expectedData {
+				t.Errorf("\nExpected stdout:\n%s\nGot:\n%s", tt.expectedData, buf.String())
 			}
 
-			// Read and verify the output file
-			outputFile, err := os.Open(fmt.Sprintf("output/%s", tt.filename))
-			if err != nil {
-				t.Errorf("Failed to open output file: %v", err)
+			if _, err := os.Stat(outputDir); err == nil {
+				t.Errorf("Expected no output directory to be created for a dry run")
 			}
-			defer outputFile.Close()
+		})
+	}
+}
 
-			reader := csv.NewReader(outputFile)
-			records, err := reader.ReadAll()
-			if err != nil {
-				t.Errorf("Failed to read CSV file: %v", err)
-			}
+func TestRun_Estimate(t *testing.T) {
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+	}()
 
-			for idx, record := range records {
-				expectedRow := strings.Join(tt.expectedFileData[idx], ",")
-				actualRow := strings.Join(record, ",")
-				if actualRow != expectedRow {
-					t.Errorf("\nFile data mistmatch at index %d.\nExpected:\n%s\nGot:\n%s", idx, expectedRow, actualRow)
-				}
-			}
-		})
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	outputDir := filepath.Join(t.TempDir(), "estimate")
+	err := run([]string{"-estimate", "-rows", "1000000", "-outdir", outputDir, "-seed", "1"})
+	w.Close()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+
+	expected := "Estimated output size: 17.6 MB (1000000 rows of about 17.6 bytes)\n"
+	if buf.String() != expected {
+		t.Errorf("\nExpected stderr:\n%s\nGot:\n%s", expected, buf.String())
+	}
+
+	if _, err := os.Stat(outputDir); err == nil {
+		t.Errorf("Expected no output directory to be created for an estimate")
 	}
 }
 
-func TestGenerate_ErrorCases(t *testing.T) {
-	rows := 1
-	fields := "email"
-	filename := "output.csv"
-	seed := 1
+func TestRun_CountOnly(t *testing.T) {
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+	}()
 
-	tests := []struct {
-		name          string
-		args          []string
-		fileHandler   FileHandler
-		fileWriter    FileWriter
-		dataGenerator DataGenerator
-		expectedError string
-	}{
-		{
-			name:          "Generate csv data fails",
-			fileHandler:   &MockFileHandler{},
-			fileWriter:    &MockFileWriter{},
-			dataGenerator: &MockDataGenerator{ShouldFail: true},
-			expectedError: "Failed to generate CSV data: generateCsvData failed",
-		},
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	outputDir := filepath.Join(t.TempDir(), "countonly")
+	err := run([]string{"-countonly", "-rows", "1000", "-fields", "name,email,address", "-workers", "2", "-outdir", outputDir, "-seed", "1"})
+	w.Close()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			defer func() {
-				if err := recover(); err != nil {
-					if err != tt.expectedError {
-						t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
-					}
-				}
-			}()
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
 
-			generate(tt.fileHandler, tt.fileWriter, tt.dataGenerator, &rows, &fields, &filename, &seed)
-		})
+	pattern := regexp.MustCompile(`^Generated 1000 rows in [0-9.]+ seconds \([0-9]+ rows/sec\)\n$`)
+	if !pattern.MatchString(buf.String()) {
+		t.Errorf("Expected stderr to match %s, got: %q", pattern, buf.String())
+	}
+
+	if _, err := os.Stat(outputDir); err == nil {
+		t.Errorf("Expected no output directory to be created when only counting")
 	}
 }
 
-func TestGenerate_SuccessCases(t *testing.T) {
-	origStdout := os.Stdout
+func TestRun_MaxBytes(t *testing.T) {
+	origStderr := os.Stderr
 	defer func() {
-		os.Stdout = origStdout
+		os.Stderr = origStderr
 	}()
 
-	rows := 1
-	fields := "email"
-	filename := "output.csv"
-	seed := 1
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer w.Close()
 
-	tests := []struct {
-		name          string
-		args          []string
-		fileHandler   FileHandler
-		fileWriter    FileWriter
-		dataGenerator DataGenerator
-		expectedOut   string
-	}{
-		{
-			name:          "Generate csv data success",
-			args:          []string{"cmd", "-rows", "1"},
-			fileHandler:   &MockFileHandler{},
-			fileWriter:    &MockFileWriter{},
-			dataGenerator: &MockDataGenerator{ShouldFail: false},
-			expectedOut:   "CSV file successfully generated at output/output.csv.",
-		},
+	outputDir := t.TempDir()
+	reportPath := filepath.Join(outputDir, "report.json")
+	err := run([]string{"-maxbytes", "5000", "-rows", "1", "-fields", "name,email,address", "-outdir", outputDir, "-seed", "1", "-report", reportPath})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			r, w, _ := os.Pipe()
-			os.Stdout = w
+	data, err := os.ReadFile(filepath.Join(outputDir, "output.csv"))
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
 
-			generate(tt.fileHandler, tt.fileWriter, tt.dataGenerator, &rows, &fields, &filename, &seed)
+	// The last row crosses the limit, so without it the file is under the limit.
+	lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+	lastRow := lines[len(lines)-1]
+	if len(data) < 5000 || len(data)-len(lastRow)-1 >= 5000 {
+		t.Errorf("Expected the file to cross 5000 bytes on its last row, got %d bytes with a last row of %d", len(data), len(lastRow)+1)
+	}
 
-			w.Close()
-			var buf bytes.Buffer
-			io.Copy(&buf, r)
+	reportData, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
 
-			actualOut := buf.String()
-			lines := strings.Split(actualOut, "\n")
-			output := lines[4]
+	var report csvgen.Report
+	if err := json.Unmarshal(reportData, &report); err != nil {
+		t.Fatalf("Failed to parse report: %v", err)
+	}
 
-			if output != tt.expectedOut {
-				t.Errorf("\nExpected output:\n%s\nGot:\n%s", tt.expectedOut, output)
-			}
-		})
+	if expected := int64(len(lines) - 1); report.Rows != expected {
+		t.Errorf("Expected the report to count %d rows, got %d", expected, report.Rows)
 	}
 }
 
-func TestGenerateCsvData_ErrorCases(t *testing.T) {
-	rows := 1
-	fields := "email"
-	outputDir := "output"
-	filename := "output.csv"
-	dataGenerator := CSVDataGenerator{}
+func TestRun_Static(t *testing.T) {
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+	}()
+
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer w.Close()
 
-	tests := []struct {
-		name          string
-		args          []string
-		fileHandler   FileHandler
-		fileWriter    FileWriter
-		expectedError string
-	}{
-		{
-			name:          "FileHandler.MkDirAll fails",
-			fileHandler:   &MockFileHandler{ShouldFailMkDirAll: true},
-			fileWriter:    &MockFileWriter{ShouldFail: false},
-			expectedError: "failed to create directory: MkDirAll failed",
-		},
-		{
-			name:          "FilerHandler.Create fails",
-			fileHandler:   &MockFileHandler{ShouldFailCreate: true},
-			fileWriter:    &MockFileWriter{ShouldFail: false},
-			expectedError: "Create failed",
-		},
-		{
-			name:          "FileWriter.Write fails",
-			fileHandler:   &MockFileHandler{},
-			fileWriter:    &MockFileWriter{ShouldFail: true},
-			expectedError: "failed to write header row: Write failed",
-		},
+	outputDir := t.TempDir()
+	err := run([]string{"-static", "-rows", "500", "-fields", "id,name,uuid,int(1,1000000)", "-workers", "4", "-outdir", outputDir, "-seed", "1"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := dataGenerator.generateCsvData(rows, fields, outputDir, filename, tt.fileHandler, tt.fileWriter)
+	file, err := os.Open(filepath.Join(outputDir, "output.csv"))
+	if err != nil {
+		t.Fatalf("Failed to open output file: %v", err)
+	}
+	defer file.Close()
 
-			if err == nil || err.Error() != tt.expectedError {
-				t.Errorf("Expected error: %v\nGot: %v", tt.expectedError, err)
-			}
-		})
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+
+	if len(records) != 501 {
+		t.Fatalf("Expected a header and 500 rows, got %d records", len(records))
+	}
+
+	expected := []string{"1", "Zion Brakus"}
+	if !slices.Equal(records[1][:2], expected) {
+		t.Errorf("Expected the repeated row to be the first generated row, starting with %v, got %v", expected, records[1])
+	}
+
+	for i, record := range records[2:] {
+		if !slices.Equal(record, records[1]) {
+			t.Fatalf("Expected row %d to be the same as the first row %v, got %v", i+2, records[1], record)
+		}
 	}
 }
 
-func TestGenerateCsvData_SuccessCases(t *testing.T) {
-	rows := 1
-	fields := "email"
-	outputDir := "output"
-	filename := "output.csv"
-	dataGenerator := CSVDataGenerator{}
+func TestRun_SequentialIDs(t *testing.T) {
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+	}()
 
-	tests := []struct {
-		name        string
-		args        []string
-		fileHandler FileHandler
-		fileWriter  FileWriter
-	}{
-		{
-			name:        "Successfully write to csv data file",
-			fileHandler: &MockFileHandler{},
-			fileWriter:  &MockFileWriter{},
-		},
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer w.Close()
+
+	outputDir := t.TempDir()
+	expectedIDs := []string{"1", "2", "3", "4", "5"}
+
+	// IDs start from 1 again on every run, whatever the seed or number of workers.
+	for _, args := range [][]string{{"-seed", "1"}, {"-seed", "2", "-workers", "3"}} {
+		err := run(append([]string{"-fields", "id,name", "-rows", "5", "-outdir", outputDir}, args...))
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		outputFile, err := os.Open(filepath.Join(outputDir, "output.csv"))
+		if err != nil {
+			t.Fatalf("Failed to open output file: %v", err)
+		}
+
+		records, err := csv.NewReader(outputFile).ReadAll()
+		outputFile.Close()
+		if err != nil {
+			t.Fatalf("Failed to read CSV file: %v", err)
+		}
+
+		ids := []string{}
+		for _, record := range records[1:] {
+			ids = append(ids, record[0])
+		}
+
+		if !slices.Equal(ids, expectedIDs) {
+			t.Errorf("Expected IDs %v with %v, got %v", expectedIDs, args, ids)
+		}
 	}
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := dataGenerator.generateCsvData(rows, fields, outputDir, filename, tt.fileHandler, tt.fileWriter)
+func TestRun_JSONFormat(t *testing.T) {
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+	}()
 
-			if err != nil {
-				t.Errorf("Expected no error, got: %v", err)
-			}
-		})
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	outputDir := t.TempDir()
+	err := run([]string{"-format", "json", "-rows", "2", "-fields", "name,age,address", "-outdir", outputDir, "-filename", "output.json", "-seed", "1"})
+	w.Close()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "output.json"))
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+
+	expectedData := `{"name":"Zion Brakus","age":"59","address":"152 West Wayborough, Omaha, Alabama 11322"}` + "\n" +
+		`{"name":"Federico Prosacco","age":"66","address":"401 Lake Hillberg, Pittsburgh, Hawaii 51299"}` + "\n"
+	if string(data) != expectedData {
+		t.Errorf("\nExpected file data:\n%s\nGot:\n%s", expectedData, data)
+	}
+
+	for idx, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var record map[string]string
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Errorf("Failed to parse line %d as JSON: %v", idx, err)
+		}
+	}
+}
+
+func TestRun_FixedWidthFormat(t *testing.T) {
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+	}()
+
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	outputDir := t.TempDir()
+	err := run([]string{"-format", "fixed", "-widths", "12,3,20", "-rows", "2", "-fields", "name,age,address", "-outdir", outputDir, "-filename", "output.txt", "-seed", "1"})
+	w.Close()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "output.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+
+	expectedData := "Zion Brakus 59 152 West Wayborough,\n" +
+		"Federico Pro66 401 Lake Hillberg, P\n"
+	if string(data) != expectedData {
+		t.Errorf("\nExpected file data:\n%s\nGot:\n%s", expectedData, data)
+	}
+}
+
+func TestRun_MultipleWorkers(t *testing.T) {
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+	}()
+
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	outputDir := t.TempDir()
+	err := run([]string{"-workers", "4", "-rows", "1000", "-fields", "name,email", "-outdir", outputDir, "-filename", "workers.csv"})
+	w.Close()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	outputFile, err := os.Open(filepath.Join(outputDir, "workers.csv"))
+	if err != nil {
+		t.Fatalf("Failed to open output file: %v", err)
+	}
+	defer outputFile.Close()
+
+	records, err := csv.NewReader(outputFile).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV file: %v", err)
+	}
+
+	if len(records) != 1001 {
+		t.Errorf("Expected 1001 records, got %d", len(records))
+	}
+
+	// Base fields are generated once per row, so each email should still belong to the
+	// name in the same row when rows are generated concurrently.
+	for idx, record := range records[1:] {
+		firstName := strings.ToLower(strings.Split(record[0], " ")[0])
+		if !strings.HasPrefix(record[1], firstName+".") {
+			t.Errorf("Row %d has mismatched name and email: %v", idx+1, record)
+		}
 	}
 }
+
+func TestRun_FieldsFile(t *testing.T) {
+	origStderr := os.Stderr
+	defer func() {
+		os.Stderr = origStderr
+	}()
+
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer w.Close()
+
+	outputDir := t.TempDir()
+	writeFieldsFile := func(contents string) string {
+		path := filepath.Join(outputDir, "fields.txt")
+		if err := os.WriteFile(path, []byte(contents), 0666); err != nil {
+			t.Fatalf("Failed to write fields file: %v", err)
+		}
+		return path
+	}
+
+	t.Run("Used instead of -fields", func(t *testing.T) {
+		path := writeFieldsFile("id\nname\n")
+		if err := run([]string{"-fieldsfile", path, "-fields", "age", "-outdir", outputDir, "-seed", "1"}); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(outputDir, "output.csv"))
+		if err != nil {
+			t.Fatalf("Failed to read output file: %v", err)
+		}
+
+		expectedData := "id,name\n1,Zion Brakus\n"
+		if string(data) != expectedData {
+			t.Errorf("\nExpected file data:\n%s\nGot:\n%s", expectedData, data)
+		}
+	})
+
+	t.Run("Invalid fields", func(t *testing.T) {
+		path := writeFieldsFile("name\nfoo\n")
+		err := run([]string{"-fieldsfile", path, "-outdir", outputDir})
+
+		expectedError := "Unable to generate CSV data. Invalid fields selected: foo. Valid fields are: " + strings.Join(csvgen.SupportedFields(), ", ")
+		if err == nil || err.Error() != expectedError {
+			t.Errorf("Expected error: %v\nGot: %v", expectedError, err)
+		}
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		path := filepath.Join(outputDir, "missing.txt")
+		err := run([]string{"-fieldsfile", path, "-outdir", outputDir})
+
+		expectedError := fmt.Sprintf("Invalid options: failed to read fields file: open %s: no such file or directory", path)
+		if err == nil || err.Error() != expectedError {
+			t.Errorf("Expected error: %v\nGot: %v", expectedError, err)
+		}
+	})
+
+	t.Run("Empty file", func(t *testing.T) {
+		path := writeFieldsFile("\n")
+		err := run([]string{"-fieldsfile", path, "-outdir", outputDir})
+
+		expectedError := "Invalid options: fields cannot be empty"
+		if err == nil || err.Error() != expectedError {
+			t.Errorf("Expected error: %v\nGot: %v", expectedError, err)
+		}
+	})
+}